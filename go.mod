@@ -3,10 +3,25 @@ module github.com/vault-thirteen/Forum-Crawler
 go 1.25.12
 
 require (
+	github.com/andybalholm/brotli v1.2.0
 	github.com/go-sql-driver/mysql v1.10.0
+	github.com/lib/pq v1.12.3
 	github.com/vault-thirteen/auxie v0.36.6
 	golang.org/x/net v0.57.0
 	golang.org/x/text v0.40.0
+	modernc.org/sqlite v1.46.1
 )
 
-require filippo.io/edwards25519 v1.2.0 // indirect
+require (
+	filippo.io/edwards25519 v1.2.0 // indirect
+	github.com/dustin/go-humanize v1.0.1 // indirect
+	github.com/google/uuid v1.6.0 // indirect
+	github.com/mattn/go-isatty v0.0.20 // indirect
+	github.com/ncruces/go-strftime v1.0.0 // indirect
+	github.com/remyoudompheng/bigfft v0.0.0-20230129092748-24d4a6f8daec // indirect
+	golang.org/x/exp v0.0.0-20251023183803-a4bb9ffd2546 // indirect
+	golang.org/x/sys v0.47.0 // indirect
+	modernc.org/libc v1.67.6 // indirect
+	modernc.org/mathutil v1.7.1 // indirect
+	modernc.org/memory v1.11.0 // indirect
+)
@@ -0,0 +1,10 @@
+--// Normal topics //--
+SELECT ID, Name, ForumId FROM Topics AS t WHERE NameTsv @@ plainto_tsquery('simple', 'Something');
+
+--// Archived topics //--
+SELECT ID, Name, ForumId FROM TopicsArchived AS t WHERE NameTsv @@ plainto_tsquery('simple', 'Something');
+
+--// All topics //--
+SELECT ID, Name, ForumId FROM Topics AS t WHERE NameTsv @@ plainto_tsquery('simple', 'Something')
+UNION
+SELECT ID, Name, ForumId FROM TopicsArchived AS t WHERE NameTsv @@ plainto_tsquery('simple', 'Something');
@@ -0,0 +1,35 @@
+package models
+
+import "time"
+
+const (
+	CrawlStatus_InProgress = "in_progress"
+	CrawlStatus_Completed  = "completed"
+	CrawlStatus_Skipped    = "skipped"
+
+	// CrawlStatus_Abandoned is set for an unfinished run, when a new run is
+	// started instead of resuming it.
+	CrawlStatus_Abandoned = "abandoned"
+)
+
+// CrawlRun is a single run of a crawl over many forums. An unfinished run is
+// resumed by the next run of the same kind.
+type CrawlRun struct {
+	Id        string
+	Kind      string
+	Status    string
+	StartedAt time.Time
+	UpdatedAt time.Time
+}
+
+// CrawlState is a checkpoint of a forum crawled by a run. Pages up to the
+// last page are saved.
+type CrawlState struct {
+	RunId     string
+	ForumId   uint
+	LastPage  uint
+	PageCount uint
+	Status    string
+	StartedAt time.Time
+	UpdatedAt time.Time
+}
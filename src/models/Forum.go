@@ -3,4 +3,13 @@ package models
 type Forum struct {
 	ID   uint
 	Name string
+
+	// Hierarchy.
+	// Top-level forums have zero parent ID and zero depth. Order is a position
+	// of the forum in the list of all forums, starting from 1.
+	CategoryId   uint
+	CategoryName string
+	ParentId     uint
+	Order        uint
+	Depth        uint
 }
@@ -1,7 +1,14 @@
 package models
 
+import "time"
+
 type Topic struct {
-	ForumId uint
-	Id      uint
-	Name    string
+	ForumId uint   `json:"forumId"`
+	Id      uint   `json:"id"`
+	Name    string `json:"name"`
+
+	// Time when the topic was seen by a crawl. The database keeps the first
+	// time, when the topic is inserted, and updates the last time.
+	FirstSeenAt time.Time `json:"-"`
+	LastSeenAt  time.Time `json:"-"`
 }
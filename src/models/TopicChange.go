@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+// TopicChange is a change of topic's name or forum observed by a crawl.
+type TopicChange struct {
+	TopicId    uint
+	OldName    string
+	NewName    string
+	OldForumId uint
+	NewForumId uint
+	ObservedAt time.Time
+}
@@ -0,0 +1,17 @@
+package models
+
+// TopicsUpdateStats counts changes made by an update of topics. A topic which
+// is both renamed and moved is counted twice.
+type TopicsUpdateStats struct {
+	Added     uint
+	Renamed   uint
+	Moved     uint
+	Unchanged uint
+}
+
+func (s *TopicsUpdateStats) Add(x *TopicsUpdateStats) {
+	s.Added += x.Added
+	s.Renamed += x.Renamed
+	s.Moved += x.Moved
+	s.Unchanged += x.Unchanged
+}
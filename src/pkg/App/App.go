@@ -9,42 +9,54 @@ import (
 	"log"
 	"net/http"
 	"os"
-	"strconv"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/vault-thirteen/Forum-Crawler/src/models"
 	"github.com/vault-thirteen/Forum-Crawler/src/pkg/CLIArguments"
+	"github.com/vault-thirteen/Forum-Crawler/src/pkg/cache"
 	"github.com/vault-thirteen/Forum-Crawler/src/pkg/db"
-	htmldom "github.com/vault-thirteen/auxie/HTML-DOM"
+	"github.com/vault-thirteen/Forum-Crawler/src/pkg/discourse"
+	"github.com/vault-thirteen/Forum-Crawler/src/pkg/export"
+	"github.com/vault-thirteen/Forum-Crawler/src/pkg/profile"
+	"github.com/vault-thirteen/Forum-Crawler/src/pkg/web"
 	ae "github.com/vault-thirteen/auxie/errors"
 	"github.com/vault-thirteen/auxie/number"
 	"golang.org/x/net/html"
-	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding"
 )
 
 const (
-	AttributeId   = "id"
-	AttributeHref = "href"
+	ErrDomNodeIsNotFound       = "DOM node is not found"
+	ErrCsvSyntax               = "CSV syntax error: %v"
+	ErrUnknownParentForum      = "unknown parent forum: %v"
+	ErrForumCycle              = "cycle in forum hierarchy: %v"
+	ErrNoForumsInCategory      = "no forums in category: %v"
+	ErrForumIsNotFound         = "forum is not found: %v"
+	ErrNoStorage               = "neither database nor output files are enabled"
+	ErrStorageIsNotMigratable  = "storage does not support migrations"
+	ErrStorageHasNoHistory     = "storage does not support history of topics"
+	ErrOfflineWithoutCache     = "offline mode requires the page cache"
+	ErrPageIsNotFetchedOffline = "page is not fetched in offline mode: %v"
 )
 
 const (
-	ErrDomNodeIsNotFound       = "DOM node is not found"
-	ErrTrWithIdIsNotFound      = "<tr> with ID is not found"
-	ErrAWithIdIsNotFound       = "<a> with ID is not found"
-	ErrNoNumberInId            = "no number in ID: %v"
-	ErrIdAttributeIsNotFound   = "ID attribute is not found"
-	ErrHrefAttributeIsNotFound = "href attribute is not found"
-	ErrTopicIdMismatch         = "topic ID mismatch: %v vs %v"
-	ErrHrefMismatch            = "href mismatch: %v vs %v"
-	ErrNoPageNumbers           = "no page numbers"
-	ErrCsvSyntax               = "CSV syntax error: %v"
-	ErrUnsupportedEncoding     = "unsupported encoding: %v"
+	// Forums file has either two columns: 'forum_id', 'forum_name', or five
+	// columns: 'forum_id', 'forum_name', 'category_id', 'category_name',
+	// 'parent_forum_id'.
+	CsvColumnsCount         = 2
+	CsvExtendedColumnsCount = 5
+)
+
+const (
+	CaptchaMaxAttempts    = 3
+	DefaultCaptchaWaitSec = 600
 )
 
 const (
 	PageNumberAllPages = 0
-	TagWbr             = `<wbr/>`
 )
 
 type App struct {
@@ -53,7 +65,19 @@ type App struct {
 	Settings *models.Settings
 
 	// Internal Structures.
-	Db *db.DB
+	Db      db.Storage
+	Client  *web.Client
+	Jar     *web.CookieJar
+	Cache   *cache.PageCache
+	Profile *profile.Profile
+
+	// Login.
+	loginLock  sync.Mutex
+	loggedInAt time.Time
+
+	// Discourse.
+	discourseLock       sync.Mutex
+	discourseCategories *discourse.Categories
 
 	// Various Data.
 	Forums []*models.Forum
@@ -69,7 +93,30 @@ func NewApp(cliArgs *cli.Arguments) (app *App, err error) {
 		return nil, err
 	}
 
-	app.Db, err = db.NewDB(app.Settings.Database)
+	app.Client, err = app.initClient()
+	if err != nil {
+		return nil, err
+	}
+
+	app.Cache, err = app.initCache()
+	if err != nil {
+		return nil, err
+	}
+
+	app.Profile, err = profile.Load(app.Settings.Profile, app.Settings.ProfilesFolder)
+	if err != nil {
+		return nil, err
+	}
+
+	switch cliArgs.Action {
+	case cli.ActionMigrate:
+		app.Settings.Database.MigrationOnly = true
+		app.Db, err = db.NewStorage(app.Settings.Database)
+	case cli.ActionShow:
+		app.Db, err = db.NewStorage(app.Settings.Database)
+	default:
+		app.Db, err = app.initStorage()
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -112,6 +159,66 @@ func NewApp(cliArgs *cli.Arguments) (app *App, err error) {
 			return nil, fmt.Errorf(cli.ErrUnsupportedObject, cliArgs.Object)
 		}
 
+	case cli.ActionUpdate:
+		switch cliArgs.Object {
+		case cli.ObjectForumTopics: // update forum_topics.
+			err = app.updateForumTopics()
+			if err != nil {
+				return nil, err
+			}
+
+		case cli.ObjectAllTopics: // update all_topics.
+			err = app.updateAllTopics()
+			if err != nil {
+				return nil, err
+			}
+
+		default: // update *.
+			return nil, fmt.Errorf(cli.ErrUnsupportedObject, cliArgs.Object)
+		}
+
+	case cli.ActionDiscover:
+		switch cliArgs.Object {
+		case cli.ObjectForums: // discover forums.
+			err = app.discoverForums()
+			if err != nil {
+				return nil, err
+			}
+
+		default: // discover *.
+			return nil, fmt.Errorf(cli.ErrUnsupportedObject, cliArgs.Object)
+		}
+
+	case cli.ActionShow:
+		switch cliArgs.Object {
+		case cli.ObjectTopicHistory: // show topic_history.
+			err = app.showTopicHistory()
+			if err != nil {
+				return nil, err
+			}
+
+		default: // show *.
+			return nil, fmt.Errorf(cli.ErrUnsupportedObject, cliArgs.Object)
+		}
+
+	case cli.ActionMigrate:
+		switch cliArgs.Object {
+		case cli.ObjectUp: // migrate up.
+			err = app.migrateUp()
+			if err != nil {
+				return nil, err
+			}
+
+		case cli.ObjectStatus: // migrate status.
+			err = app.showMigrationStatus()
+			if err != nil {
+				return nil, err
+			}
+
+		default: // migrate *.
+			return nil, fmt.Errorf(cli.ErrUnsupportedObject, cliArgs.Object)
+		}
+
 	default: // * *.
 		return nil, fmt.Errorf(cli.ErrUnknownAction, cliArgs.Action)
 	}
@@ -134,10 +241,110 @@ func (a *App) getSettings(file string) (s *models.Settings, err error) {
 
 	// Additional settings.
 	s.Database.TemporaryFolder = s.TemporaryFolder
+	if (s.Discourse != nil) && (s.TopicsPerPage == 0) {
+		s.TopicsPerPage = discourse.DefaultTopicsPerPage
+	}
 
 	return s, nil
 }
 
+// initClient creates the HTTP client configured in settings.
+func (a *App) initClient() (client *web.Client, err error) {
+	header := http.Header{}
+	header.Set(web.HttpHeaderUserAgent, a.Settings.UserAgent)
+
+	// A crawler which logs in by itself keeps session cookies in a jar.
+	var jar http.CookieJar
+	if a.hasAuth() {
+		a.Jar, err = web.NewCookieJar(a.Settings.Auth.CookieFile)
+		if err != nil {
+			return nil, err
+		}
+		jar = a.Jar
+	} else {
+		header.Set(web.HttpHeaderCookie, a.Settings.Cookie)
+	}
+
+	// Old settings have a fixed delay between requests instead of a rate.
+	httpSettings := &models.HttpSettings{}
+	if a.Settings.Http != nil {
+		*httpSettings = *a.Settings.Http
+	}
+	if (httpSettings.RequestsPerSecond == 0) && (a.Settings.ForumTopicsPageDelaySec > 0) {
+		httpSettings.RequestsPerSecond = 1 / a.Settings.ForumTopicsPageDelaySec
+	}
+
+	return web.NewClient(httpSettings, header, jar)
+}
+
+// initCache creates the page cache configured in settings. Offline mode is
+// turned on either by settings or by the 'offline' parameter.
+func (a *App) initCache() (pageCache *cache.PageCache, err error) {
+	if a.CLIArgs.HasParameter(cli.Parameter_Offline) {
+		var offline bool
+		offline, err = a.CLIArgs.GetOffline()
+		if err != nil {
+			return nil, err
+		}
+
+		if offline {
+			if a.Settings.Cache == nil {
+				return nil, errors.New(ErrOfflineWithoutCache)
+			}
+			a.Settings.Cache.Offline = true
+		}
+	}
+
+	if a.Settings.Cache == nil {
+		return nil, nil
+	}
+
+	return cache.NewPageCache(a.Settings.Cache.Folder)
+}
+
+// initStorage creates the database and file sinks configured in settings.
+func (a *App) initStorage() (storage db.Storage, err error) {
+	output := a.Settings.Output
+	if output == nil {
+		return db.NewStorage(a.Settings.Database)
+	}
+
+	storages := make([]db.Storage, 0, len(output.Formats)+1)
+	defer func() {
+		if err != nil {
+			for _, s := range storages {
+				cerr := s.Close()
+				if cerr != nil {
+					err = ae.Combine(err, cerr)
+				}
+			}
+		}
+	}()
+
+	if !output.DisableDatabase {
+		storage, err = db.NewStorage(a.Settings.Database)
+		if err != nil {
+			return nil, err
+		}
+		storages = append(storages, storage)
+	}
+
+	var sinks []*export.FileSink
+	sinks, err = export.NewFileSinks(output)
+	if err != nil {
+		return nil, err
+	}
+	for _, sink := range sinks {
+		storages = append(storages, sink)
+	}
+
+	if len(storages) == 0 {
+		return nil, errors.New(ErrNoStorage)
+	}
+
+	return db.NewMultiStorage(storages...), nil
+}
+
 func (a *App) Close() (err error) {
 	err = a.Db.Close()
 	if err != nil {
@@ -147,11 +354,97 @@ func (a *App) Close() (err error) {
 	return nil
 }
 
+// migrateUp applies pending migrations of the database schema.
+func (a *App) migrateUp() (err error) {
+	log.Println("Migrating the database schema")
+
+	m, ok := a.Db.(db.Migratable)
+	if !ok {
+		return errors.New(ErrStorageIsNotMigratable)
+	}
+
+	var appliedCount int
+	appliedCount, err = m.MigrateUp()
+	if err != nil {
+		return err
+	}
+
+	log.Println(fmt.Sprintf("Migrations applied: %v.", appliedCount))
+
+	return nil
+}
+
+// showMigrationStatus prints the list of migrations of the database schema.
+func (a *App) showMigrationStatus() (err error) {
+	m, ok := a.Db.(db.Migratable)
+	if !ok {
+		return errors.New(ErrStorageIsNotMigratable)
+	}
+
+	var statuses []*db.MigrationStatus
+	statuses, err = m.MigrationStatus()
+	if err != nil {
+		return err
+	}
+
+	for _, st := range statuses {
+		if st.IsApplied {
+			fmt.Println(fmt.Sprintf("%03d %v: applied at %v", st.Version, st.Name, st.AppliedAt.Format(time.RFC3339)))
+		} else {
+			fmt.Println(fmt.Sprintf("%03d %v: pending", st.Version, st.Name))
+		}
+	}
+
+	return nil
+}
+
+// showTopicHistory prints changes of a topic's name and forum.
+func (a *App) showTopicHistory() (err error) {
+	var topicId uint
+	topicId, err = a.CLIArgs.GetTopicId()
+	if err != nil {
+		return err
+	}
+
+	hr, ok := a.Db.(db.TopicHistoryReader)
+	if !ok {
+		return errors.New(ErrStorageHasNoHistory)
+	}
+
+	var changes []*models.TopicChange
+	changes, err = hr.GetTopicHistory(topicId)
+	if err != nil {
+		return err
+	}
+
+	if len(changes) == 0 {
+		fmt.Println(fmt.Sprintf("Topic ID=%v has no changes.", topicId))
+		return nil
+	}
+
+	for _, c := range changes {
+		fmt.Println(fmt.Sprintf("%v: Topic ID=%v", c.ObservedAt.Format(time.RFC3339), c.TopicId))
+		if c.OldName != c.NewName {
+			fmt.Println(fmt.Sprintf("\tName: %v -> %v", c.OldName, c.NewName))
+		}
+		if c.OldForumId != c.NewForumId {
+			fmt.Println(fmt.Sprintf("\tForum ID: %v -> %v", c.OldForumId, c.NewForumId))
+		}
+	}
+
+	return nil
+}
+
 // initForums reads forums from a file and saves them into the database.
+// Forums of a Discourse board without the file are read from the board.
 func (a *App) initForums() (forums []*models.Forum, err error) {
 	log.Println("Initializing list of forums")
 
-	forums, err = a.getForums(a.Settings.ForumsFile)
+	if a.isDiscourse() && (len(a.Settings.ForumsFile) == 0) {
+		forums, err = a.getDiscourseForums()
+	} else {
+		forums, err = a.getForums(a.Settings.ForumsFile)
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -182,22 +475,40 @@ func (a *App) getForums(forumsFile string) (forums []*models.Forum, err error) {
 
 	var forum *models.Forum
 	forums = make([]*models.Forum, 0, len(records))
-	for _, rec := range records {
-		if len(rec) != 2 {
+	for i, rec := range records {
+		if (len(rec) != CsvColumnsCount) && (len(rec) != CsvExtendedColumnsCount) {
 			return nil, fmt.Errorf(ErrCsvSyntax, rec)
 		}
 
 		forum = &models.Forum{
-			Name: rec[1],
+			Name:  rec[1],
+			Order: uint(i + 1),
 		}
 		forum.ID, err = number.ParseUint(rec[0])
 		if err != nil {
 			return nil, err
 		}
 
+		if len(rec) == CsvExtendedColumnsCount {
+			forum.CategoryId, err = parseOptionalUint(rec[2])
+			if err != nil {
+				return nil, err
+			}
+			forum.CategoryName = rec[3]
+			forum.ParentId, err = parseOptionalUint(rec[4])
+			if err != nil {
+				return nil, err
+			}
+		}
+
 		forums = append(forums, forum)
 	}
 
+	err = setForumDepths(forums)
+	if err != nil {
+		return nil, err
+	}
+
 	return forums, nil
 }
 
@@ -232,62 +543,80 @@ func (a *App) initForumTopics() (err error) {
 		return err
 	}
 
+	crawlStartedAt := getCrawlTime()
+
 	var topics map[uint]*models.Topic
-	topics, err = a.getForumTopics(forumId, pageNumber)
+	topics, err = a.getForumTopics(forumId, pageNumber, os.Stdout)
+	if err != nil {
+		return err
+	}
+
+	err = a.saveTopics(forumId, topics, os.Stdout)
 	if err != nil {
 		return err
 	}
 
-	return a.saveTopics(forumId, topics)
+	if pageNumber != PageNumberAllPages {
+		return nil
+	}
+
+	return a.markMissingTopics(forumId, crawlStartedAt, false)
 }
 
 // initAllTopics reads topics of all forums from internet and saves them into
 // the database.
-// The 'startForumId' is used to resume updates on a selected forum. If it is
-// set to zero, all forums are scanned without resuming.
+// Forums may be selected by a category or by a root forum of a subtree. The
+// 'startForumId' is used to resume updates on a selected forum. If it is set
+// to zero, all forums are scanned without resuming.
 func (a *App) initAllTopics() (err error) {
 	a.Forums, err = a.initForums()
 	if err != nil {
 		return err
 	}
 
-	var startForumId uint
-	startForumId, err = a.CLIArgs.GetStartForumId()
+	var forums []*models.Forum
+	forums, err = a.selectForums(a.Forums)
 	if err != nil {
 		return err
 	}
 
-	var startForumIsFound bool
-	if startForumId == 0 {
-		startForumIsFound = true
+	log.Println("Initializing all topics")
+
+	var cp *checkpoints
+	cp, err = a.startCrawlRun(a.getCrawlKind())
+	if err != nil {
+		return err
 	}
 
-	log.Println("Initializing all topics")
+	saver := &forumSaver{
+		savePage: func(job *forumJob, page *forumPage, progress io.Writer) (err error) {
+			err = a.saveTopics(job.forum.ID, page.topics, progress)
+			if err != nil {
+				return err
+			}
 
-	var topics map[uint]*models.Topic
+			return cp.savePage(job, page)
+		},
+		finish: func(job *forumJob, isSkipped bool) (err error) {
+			if isSkipped {
+				return cp.finishForum(job, models.CrawlStatus_Skipped)
+			}
 
-	for _, forum := range a.Forums {
-		if !startForumIsFound {
-			// Skip some forums until we find the one for a good start.
-			if forum.ID != startForumId {
-				continue
-			} else {
-				startForumIsFound = true
+			err = a.markMissingTopics(job.forum.ID, job.crawlStartedAt, false)
+			if err != nil {
+				return err
 			}
-		}
 
-		topics, err = a.getForumTopics(forum.ID, PageNumberAllPages)
-		if err != nil {
-			return err
-		}
+			return cp.finishForum(job, models.CrawlStatus_Completed)
+		},
+	}
 
-		err = a.saveTopics(forum.ID, topics)
-		if err != nil {
-			return err
-		}
+	err = a.crawlForums(cp.planJobs(forums), a.crawlAllForumPages, saver)
+	if err != nil {
+		return err
 	}
 
-	return nil
+	return cp.finishRun()
 }
 
 // refreshAllTopics reads topics from first N pages of all forums from internet
@@ -306,19 +635,146 @@ func (a *App) refreshAllTopics() (err error) {
 
 	log.Println(fmt.Sprintf("Refreshing all topics. FP=%v.", firstPagesCount))
 
-	var topics map[uint]*models.Topic
-
-	for _, forum := range a.Forums {
-		topics, err = a.getForumTopicsFromFirstPages(forum.ID, firstPagesCount)
+	// Topics of first pages are saved at once.
+	crawl := func(job *forumJob, progress io.Writer, onPage pageHandler) (err error) {
+		var topics map[uint]*models.Topic
+		topics, err = a.getForumTopicsFromFirstPages(job.forum.ID, firstPagesCount, progress)
 		if err != nil {
 			return err
 		}
 
-		err = a.saveNewTopics(forum.ID, topics)
-		if err != nil {
-			return err
-		}
+		return onPage(firstPagesCount, firstPagesCount, topics)
+	}
+
+	saver := &forumSaver{
+		savePage: func(job *forumJob, page *forumPage, progress io.Writer) (err error) {
+			return a.saveNewTopics(job.forum.ID, page.topics, progress)
+		},
+		finish: func(job *forumJob, isSkipped bool) (err error) {
+			return nil
+		},
+	}
+
+	return a.crawlForums(newForumJobs(a.Forums), crawl, saver)
+}
+
+// updateForumTopics reads forum's topics from internet, updates existing
+// topics and saves new topics into the database.
+// If 'pageNumber' is 0, all pages will be scanned, otherwise only a single page
+// will be scanned for topics.
+func (a *App) updateForumTopics() (err error) {
+	log.Println("Updating forum's topics")
+
+	var forumId uint
+	forumId, err = a.CLIArgs.GetForumId()
+	if err != nil {
+		return err
+	}
+
+	var pageNumber uint
+	pageNumber, err = a.CLIArgs.GetForumPage()
+	if err != nil {
+		return err
+	}
+
+	crawlStartedAt := getCrawlTime()
+
+	var topics map[uint]*models.Topic
+	topics, err = a.getForumTopics(forumId, pageNumber, os.Stdout)
+	if err != nil {
+		return err
+	}
+
+	var stats *models.TopicsUpdateStats
+	stats, err = a.updateTopics(forumId, topics)
+	if err != nil {
+		return err
 	}
+	logUpdateStats(forumId, stats)
+
+	if pageNumber != PageNumberAllPages {
+		return nil
+	}
+
+	return a.markMissingTopics(forumId, crawlStartedAt, forumId == a.Settings.ArchivedTopicsForumId)
+}
+
+// updateAllTopics reads topics of all forums from internet, updates existing
+// topics and saves new topics into the database.
+// Forums are selected in the same way as by 'initAllTopics'.
+func (a *App) updateAllTopics() (err error) {
+	a.Forums, err = a.initForums()
+	if err != nil {
+		return err
+	}
+
+	var forums []*models.Forum
+	forums, err = a.selectForums(a.Forums)
+	if err != nil {
+		return err
+	}
+
+	log.Println("Updating all topics")
+
+	var cp *checkpoints
+	cp, err = a.startCrawlRun(a.getCrawlKind())
+	if err != nil {
+		return err
+	}
+
+	forumStats := make(map[uint]*models.TopicsUpdateStats)
+	totalStats := &models.TopicsUpdateStats{}
+
+	saver := &forumSaver{
+		savePage: func(job *forumJob, page *forumPage, progress io.Writer) (err error) {
+			var stats *models.TopicsUpdateStats
+			stats, err = a.updateTopics(job.forum.ID, page.topics)
+			if err != nil {
+				return err
+			}
+
+			_, ok := forumStats[job.forum.ID]
+			if !ok {
+				forumStats[job.forum.ID] = &models.TopicsUpdateStats{}
+			}
+			forumStats[job.forum.ID].Add(stats)
+
+			return cp.savePage(job, page)
+		},
+		finish: func(job *forumJob, isSkipped bool) (err error) {
+			if isSkipped {
+				return cp.finishForum(job, models.CrawlStatus_Skipped)
+			}
+
+			stats, ok := forumStats[job.forum.ID]
+			if !ok {
+				stats = &models.TopicsUpdateStats{}
+			}
+			delete(forumStats, job.forum.ID)
+			logUpdateStats(job.forum.ID, stats)
+			totalStats.Add(stats)
+
+			err = a.markMissingTopics(job.forum.ID, job.crawlStartedAt, job.forum.ID == a.Settings.ArchivedTopicsForumId)
+			if err != nil {
+				return err
+			}
+
+			return cp.finishForum(job, models.CrawlStatus_Completed)
+		},
+	}
+
+	err = a.crawlForums(cp.planJobs(forums), a.crawlAllForumPages, saver)
+	if err != nil {
+		return err
+	}
+
+	err = cp.finishRun()
+	if err != nil {
+		return err
+	}
+
+	log.Println(fmt.Sprintf("All topics: added=%v, renamed=%v, moved=%v, unchanged=%v.",
+		totalStats.Added, totalStats.Renamed, totalStats.Moved, totalStats.Unchanged))
 
 	return nil
 }
@@ -326,20 +782,20 @@ func (a *App) refreshAllTopics() (err error) {
 // getForumTopics fetches forum's topics from internet.
 // If pageNumber is 0, all pages will be scanned, otherwise only a single page
 // will be scanned for topics.
-func (a *App) getForumTopics(forumId uint, pageNumber uint) (uniqueTopics map[uint]*models.Topic, err error) {
-	var pageSrc []byte
-	var topics []*models.Topic
+func (a *App) getForumTopics(forumId uint, pageNumber uint, progress io.Writer) (uniqueTopics map[uint]*models.Topic, err error) {
 	uniqueTopics = make(map[uint]*models.Topic)
 
 	// Single page fetch.
 	if pageNumber != 0 {
-		fmt.Println(fmt.Sprintf("Forum ID=%v: [%v]", forumId, pageNumber))
+		fmt.Fprintln(progress, fmt.Sprintf("Forum ID=%v: [%v]", forumId, pageNumber))
 
-		pageSrc, err = a.getForumPage(forumId, (pageNumber-1)*a.Settings.TopicsPerPage)
+		var pageSrc []byte
+		pageSrc, _, err = a.getForumPage(forumId, (pageNumber-1)*a.Settings.TopicsPerPage)
 		if err != nil {
 			return nil, err
 		}
 
+		var topics []*models.Topic
 		topics, err = a.findForumTopics(forumId, pageSrc)
 		if err != nil {
 			return nil, err
@@ -352,412 +808,552 @@ func (a *App) getForumTopics(forumId uint, pageNumber uint) (uniqueTopics map[ui
 	}
 
 	// Fetch all the pages.
-	fmt.Printf("Forum ID=%v: ", forumId)
-
-	pageSrc, err = a.getForumPage(forumId, 0)
+	err = a.crawlForumPages(forumId, 1, PageNumberAllPages, false, progress, collectTopics(uniqueTopics))
 	if err != nil {
 		return nil, err
 	}
 
-	var pageCount uint
-	pageCount, err = a.findForumPagesCount(forumId, pageSrc)
+	return uniqueTopics, nil
+}
+
+// getForumTopicsFromFirstPages fetches forum's topics from N first pages from
+// internet. Pages which have not been modified since they were cached are not
+// parsed, as their topics have already been saved.
+func (a *App) getForumTopicsFromFirstPages(forumId uint, pagesCount uint, progress io.Writer) (uniqueTopics map[uint]*models.Topic, err error) {
+	uniqueTopics = make(map[uint]*models.Topic)
+
+	err = a.crawlForumPages(forumId, 1, pagesCount, true, progress, collectTopics(uniqueTopics))
 	if err != nil {
 		return nil, err
 	}
 
-	a.sleepBetweenTopicPages()
+	return uniqueTopics, nil
+}
+
+// pageHandler handles topics found on a page of a forum. Topics which have
+// been found on previous pages are not passed again.
+type pageHandler func(pageNum uint, pageCount uint, topics map[uint]*models.Topic) (err error)
+
+// crawlForumPages fetches pages of a forum from the first page to the last
+// page and passes topics of each page to the handler. If the last page is 0,
+// pages are fetched up to the end of the forum. When 'skipUnmodified' is set,
+// pages which have not been modified are not parsed and are passed to the
+// handler without topics.
+func (a *App) crawlForumPages(forumId uint, firstPage uint, lastPage uint, skipUnmodified bool, progress io.Writer, onPage pageHandler) (err error) {
+	var pageSrc []byte
+	var isModified bool
+	var topics []*models.Topic
 
-	for pageNum := uint(1); pageNum <= pageCount; pageNum++ {
-		fmt.Printf("[%v] ", pageNum)
+	fmt.Fprintf(progress, "Forum ID=%v: ", forumId)
 
-		pageSrc, err = a.getForumPage(forumId, (pageNum-1)*a.Settings.TopicsPerPage)
+	// The first page is used to count pages. Pages of Discourse are counted
+	// while they are crawled, as each page tells only whether it is the last
+	// one.
+	var firstPageSrc []byte
+	isCountedByPages := false
+	if lastPage == PageNumberAllPages {
+		isCountedByPages = a.isDiscourse()
+
+		firstPageSrc, isModified, err = a.getForumPage(forumId, 0)
 		if err != nil {
-			return nil, err
+			return err
 		}
 
-		topics, err = a.findForumTopics(forumId, pageSrc)
+		lastPage, err = a.findForumPagesCount(forumId, firstPageSrc)
 		if err != nil {
-			return nil, err
+			return err
 		}
 
-		var topicExists bool
-		for _, topic := range topics {
-			_, topicExists = uniqueTopics[topic.Id]
-			if topicExists {
-				continue
-			}
-			uniqueTopics[topic.Id] = topic
+		// The first page tells only about the next one, so that a crawl resumed
+		// from a further page counts the rest of pages from there.
+		if isCountedByPages {
+			lastPage = max(lastPage, firstPage)
 		}
-
-		a.sleepBetweenTopicPages()
 	}
 
-	fmt.Println()
-	return uniqueTopics, nil
-}
-
-// getForumTopicsFromFirstPages fetches forum's topics from N first pages from
-// internet.
-func (a *App) getForumTopicsFromFirstPages(forumId uint, pagesCount uint) (uniqueTopics map[uint]*models.Topic, err error) {
-	var pageSrc []byte
-	var topics []*models.Topic
-	uniqueTopics = make(map[uint]*models.Topic)
+	seenTopics := make(map[uint]bool)
+	for pageNum := firstPage; pageNum <= lastPage; pageNum++ {
+		if (pageNum == 1) && (firstPageSrc != nil) {
+			pageSrc = firstPageSrc
+		} else {
+			pageSrc, isModified, err = a.getForumPage(forumId, (pageNum-1)*a.Settings.TopicsPerPage)
+			if err != nil {
+				return err
+			}
+		}
 
-	// Fetch first N pages.
-	fmt.Printf("Forum ID=%v: ", forumId)
+		if isCountedByPages {
+			var pageCount uint
+			pageCount, err = a.findForumPagesCount(forumId, pageSrc)
+			if err != nil {
+				return err
+			}
+			lastPage = max(lastPage, pageCount)
+		}
 
-	for pageNum := uint(1); pageNum <= pagesCount; pageNum++ {
-		fmt.Printf("[%v] ", pageNum)
+		if !isModified && skipUnmodified {
+			fmt.Fprintf(progress, "[%v=] ", pageNum)
 
-		pageSrc, err = a.getForumPage(forumId, (pageNum-1)*a.Settings.TopicsPerPage)
-		if err != nil {
-			return nil, err
+			err = onPage(pageNum, lastPage, map[uint]*models.Topic{})
+			if err != nil {
+				return err
+			}
+			continue
 		}
 
+		fmt.Fprintf(progress, "[%v] ", pageNum)
+
 		topics, err = a.findForumTopics(forumId, pageSrc)
 		if err != nil {
-			return nil, err
+			return err
 		}
 
-		var topicExists bool
+		pageTopics := make(map[uint]*models.Topic, len(topics))
 		for _, topic := range topics {
-			_, topicExists = uniqueTopics[topic.Id]
-			if topicExists {
+			if seenTopics[topic.Id] {
 				continue
 			}
-			uniqueTopics[topic.Id] = topic
+			seenTopics[topic.Id] = true
+			pageTopics[topic.Id] = topic
 		}
 
-		a.sleepBetweenTopicPages()
+		err = onPage(pageNum, lastPage, pageTopics)
+		if err != nil {
+			return err
+		}
 	}
 
-	fmt.Println()
-	return uniqueTopics, nil
+	fmt.Fprintln(progress)
+	return nil
 }
 
-// saveTopics saves topics into the database.
-func (a *App) saveTopics(forumId uint, topics map[uint]*models.Topic) (err error) {
+// crawlAllForumPages crawls all pages of a forum starting from the first page
+// of the job.
+func (a *App) crawlAllForumPages(job *forumJob, progress io.Writer, onPage pageHandler) (err error) {
+	return a.crawlForumPages(job.forum.ID, job.firstPage, PageNumberAllPages, false, progress, onPage)
+}
+
+// collectTopics returns a page handler which collects topics of all pages.
+func collectTopics(uniqueTopics map[uint]*models.Topic) pageHandler {
+	return func(pageNum uint, pageCount uint, topics map[uint]*models.Topic) (err error) {
+		for id, topic := range topics {
+			uniqueTopics[id] = topic
+		}
+		return nil
+	}
+}
+
+// saveTopics saves topics into the table of their forum. Big sets of topics
+// are split into batches, existing topics are updated.
+func (a *App) saveTopics(forumId uint, topics map[uint]*models.Topic, progress io.Writer) (err error) {
+	isArchived := forumId == a.Settings.ArchivedTopicsForumId
+
 	if len(topics) < db.BulkThresholdCount {
 		for _, topic := range topics {
-			err = a.Db.SaveTopic(topic)
+			err = a.Db.SaveTopic(topic, isArchived)
 			if err != nil {
 				return err
 			}
 		}
-	} else {
-		err = a.Db.SaveTopics(forumId, topics)
+		return nil
+	}
+
+	batches := splitTopicsIntoBatches(topics, a.Settings.Database.BatchSize)
+
+	fmt.Fprintf(progress, "Forum ID=%v: saving %v topics: ", forumId, len(topics))
+
+	for i, batch := range batches {
+		err = a.Db.SaveTopics(forumId, batch, isArchived)
 		if err != nil {
+			fmt.Fprintln(progress)
 			return err
 		}
+
+		fmt.Fprintf(progress, "[%v/%v] ", i+1, len(batches))
 	}
 
+	fmt.Fprintln(progress)
 	return nil
 }
 
 // saveNewTopics saves [only] new topics into the database.
-func (a *App) saveNewTopics(forumId uint, topics map[uint]*models.Topic) (err error) {
+func (a *App) saveNewTopics(forumId uint, topics map[uint]*models.Topic, progress io.Writer) (err error) {
 	var isTopicArchived = false
 	if forumId == a.Settings.ArchivedTopicsForumId {
 		isTopicArchived = true
 	}
 
 	if isTopicArchived {
-		fmt.Print("Refreshing archived topics: ")
+		fmt.Fprintf(progress, "Forum ID=%v: refreshing archived topics: ", forumId)
 	} else {
-		fmt.Print("Refreshing topics: ")
+		fmt.Fprintf(progress, "Forum ID=%v: refreshing topics: ", forumId)
 	}
 
 	for _, topic := range topics {
-		fmt.Printf("[%v] ", topic.Id)
+		fmt.Fprintf(progress, "[%v] ", topic.Id)
 		err = a.Db.SaveNewTopic(topic, isTopicArchived)
 		if err != nil {
+			fmt.Fprintln(progress)
 			return err
 		}
 	}
-	fmt.Println()
+	fmt.Fprintln(progress)
 
 	return nil
 }
 
-// getForumPage fetches source code of a specified forum page.
-func (a *App) getForumPage(forumId uint, startItemIdx uint) (pageContents []byte, err error) {
-	url := fmt.Sprintf(a.Settings.ForumUrlFormat, forumId, startItemIdx)
+// updateTopics updates existing topics and saves new topics into the
+// database.
+func (a *App) updateTopics(forumId uint, topics map[uint]*models.Topic) (stats *models.TopicsUpdateStats, err error) {
+	isTopicArchived := forumId == a.Settings.ArchivedTopicsForumId
 
-	var req *http.Request
-	req, err = http.NewRequest(http.MethodGet, url, nil)
-	if err != nil {
-		return nil, err
-	}
+	return a.Db.UpdateTopics(topics, isTopicArchived)
+}
 
-	cookie := a.Settings.Cookie
-	req.Header.Set("Cookie", cookie)
-	req.Header.Set("User-Agent", a.Settings.UserAgent)
+func logUpdateStats(forumId uint, stats *models.TopicsUpdateStats) {
+	log.Println(fmt.Sprintf("Forum ID=%v: added=%v, renamed=%v, moved=%v, unchanged=%v.",
+		forumId, stats.Added, stats.Renamed, stats.Moved, stats.Unchanged))
+}
 
-	var resp *http.Response
-	resp, err = http.DefaultClient.Do(req)
+// markMissingTopics marks topics of a forum which have not been seen by a full
+// crawl of the forum.
+func (a *App) markMissingTopics(forumId uint, crawlStartedAt time.Time, isArchived bool) (err error) {
+	var count int64
+	count, err = a.Db.MarkMissingTopics(forumId, crawlStartedAt, isArchived)
 	if err != nil {
-		return nil, err
+		return err
 	}
-	defer func() {
-		derr := resp.Body.Close()
-		if derr != nil {
-			err = ae.Combine(err, derr)
-		}
-	}()
 
-	pageContents, err = io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, err
+	if count > 0 {
+		log.Println(fmt.Sprintf("Forum ID=%v: %v topics are missing.", forumId, count))
 	}
 
-	return a.decodeBytes(pageContents)
+	return nil
 }
 
-func (a *App) decodeBytes(dataInput []byte) (utfOutput []byte, err error) {
-	switch a.Settings.PageEncoding {
-	case models.PageEncoding_UTF8:
-		return dataInput, nil
-	case models.PageEncoding_Windows1251:
-		return a.decodeWindows1251(dataInput)
-	default:
-		return nil, fmt.Errorf(ErrUnsupportedEncoding, a.Settings.PageEncoding)
+// getForumPage fetches source code of a specified forum page. Missing forum
+// pages are reported as forums which do not exist. Fetched pages are saved
+// into the page cache, if it is enabled, and cached pages are revalidated by
+// conditional requests. A page which has not been modified is read from the
+// cache. In offline mode, pages are read from the cache.
+func (a *App) getForumPage(forumId uint, startItemIdx uint) (pageContents []byte, isModified bool, err error) {
+	if a.isOffline() {
+		pageContents, err = a.Cache.Get(forumId, startItemIdx)
+		if err != nil {
+			return nil, false, err
+		}
+
+		return pageContents, true, nil
 	}
-}
 
-func (a *App) decodeWindows1251(cp1251Input []byte) (utfOutput []byte, err error) {
-	return charmap.Windows1251.NewDecoder().Bytes(cp1251Input)
-}
+	var url string
+	url, err = a.getForumPageUrl(forumId, startItemIdx)
+	if err != nil {
+		return nil, false, err
+	}
 
-// findForumPagesCount searches for the count of pages in the source code of a
-// page.
-func (a *App) findForumPagesCount(forumId uint, pageContents []byte) (pageCount uint, err error) {
-	var domNode *html.Node
-	domNode, err = html.Parse(strings.NewReader(string(pageContents)))
+	var validators *web.Validators
+	validators, err = a.getCachedValidators(forumId, startItemIdx)
 	if err != nil {
-		return 0, err
+		return nil, false, err
+	}
+
+	var page *fetchedPage
+	page, err = a.getPageIfModified(url, validators)
+	if err != nil {
+		var se *web.StatusError
+		if errors.As(err, &se) && (se.StatusCode == http.StatusNotFound) {
+			return nil, false, &web.ForumDoesNotExistError{Url: url}
+		}
+
+		return nil, false, err
 	}
-	if domNode == nil {
-		return 0, errors.New(ErrDomNodeIsNotFound)
-	}
-
-	var node = htmldom.GetChildNodeByTag(domNode, htmldom.TagHtml) // -> <doctype>
-	node = htmldom.GetSiblingNodeByTag(node, htmldom.TagHtml)      // -> <html>
-	node = htmldom.GetChildNodeByTag(node, htmldom.TagHead)        // -> <head>
-	node = htmldom.GetSiblingNodeByTag(node, htmldom.TagBody)      // -> <body>
-	node = htmldom.GetChildNodeByTagAndId(node, htmldom.TagDiv, "body_container")
-	node = htmldom.GetChildNodeByTagAndId(node, htmldom.TagDiv, "page_container")
-	node = htmldom.GetChildNodeByTagAndId(node, htmldom.TagDiv, "page_content")
-	node = htmldom.GetChildNodeByTag(node, htmldom.TagTable)                   // <table>
-	node = htmldom.GetChildNodeByTag(node, htmldom.TagTbody)                   // <tbody>
-	node = htmldom.GetChildNodeByTag(node, htmldom.TagTr)                      // <tr>
-	node = htmldom.GetChildNodeByTagAndId(node, htmldom.TagTd, "main_content") // <td id="main_content">
-	node = htmldom.GetChildNodeByTag(node, htmldom.TagDiv)                     // <div id="main_content_wrap">
-	node = htmldom.GetChildNodeByTag(node, htmldom.TagTable)                   // <table>
-	node = htmldom.GetChildNodeByTag(node, htmldom.TagTbody)                   // <tbody>
-	node = htmldom.GetChildNodeByTag(node, htmldom.TagTr)                      // <tr>
-	node = htmldom.GetChildNodeByTag(node, htmldom.TagTd)                      // <td>
-	node = htmldom.GetChildNodeByTag(node, htmldom.TagH1)                      // <h1>
-	node = htmldom.GetSiblingNodeByTag(node, htmldom.TagP)                     // -> <p>
-	node = htmldom.GetChildNodeByTag(node, htmldom.TagB)                       // -> first <b>
-	node = htmldom.GetChildNodeByTag(node, htmldom.TagB)                       // -> second <b>
-
-	pageNumbers := make([]uint, 0)
-	var pageNumber uint
-	node = htmldom.GetSiblingNodeByTag(node, htmldom.TagA) // first <a> with 'pg' class.
 
-	for {
-		pageNumber, err = a.getHtmlNodeInnerHtmlUint(node)
+	if page.isNotModified {
+		pageContents, err = a.Cache.Get(forumId, startItemIdx)
 		if err != nil {
-			break
+			return nil, false, err
 		}
-		pageNumbers = append(pageNumbers, pageNumber)
 
-		node = htmldom.GetSiblingNodeByTag(node, htmldom.TagA) // next <a> with 'pg' class.
+		return pageContents, false, nil
 	}
 
-	if len(pageNumbers) == 0 {
-		return 0, errors.New(ErrNoPageNumbers)
+	if a.Cache != nil {
+		err = a.Cache.Put(forumId, startItemIdx, page.contents, page.validators.ETag, page.validators.LastModified)
+		if err != nil {
+			return nil, false, err
+		}
 	}
-	pageNumber = pageNumbers[len(pageNumbers)-1]
 
-	return pageNumber, nil
+	return page.contents, true, nil
 }
 
-// findForumTopics searches for topics in the source code of a forum page.
-func (a *App) findForumTopics(forumId uint, pageContents []byte) (topics []*models.Topic, err error) {
-	var domNode *html.Node
-	domNode, err = html.Parse(strings.NewReader(string(pageContents)))
+// getForumPageUrl returns the address of a forum page.
+func (a *App) getForumPageUrl(forumId uint, startItemIdx uint) (url string, err error) {
+	if a.isDiscourse() {
+		return a.getDiscoursePageUrl(forumId, startItemIdx)
+	}
+
+	return fmt.Sprintf(a.Settings.ForumUrlFormat, forumId, startItemIdx), nil
+}
+
+// getCachedValidators returns validators of a cached forum page. Nil is
+// returned when the page is not cached.
+func (a *App) getCachedValidators(forumId uint, startItemIdx uint) (validators *web.Validators, err error) {
+	if a.Cache == nil {
+		return nil, nil
+	}
+
+	var info *cache.PageInfo
+	info, err = a.Cache.GetInfo(forumId, startItemIdx)
 	if err != nil {
+		var pe *cache.PageIsNotCachedError
+		if errors.As(err, &pe) {
+			return nil, nil
+		}
 		return nil, err
 	}
-	if domNode == nil {
-		return nil, errors.New(ErrDomNodeIsNotFound)
+
+	return &web.Validators{
+		ETag:         info.ETag,
+		LastModified: info.LastModified,
+	}, nil
+}
+
+// isOffline checks whether pages are read only from the page cache.
+func (a *App) isOffline() bool {
+	return (a.Settings.Cache != nil) && a.Settings.Cache.Offline
+}
+
+// fetchedPage is a page decoded into UTF-8 together with its validators. A
+// page which has not been modified since the version identified by
+// validators of a conditional request has no contents.
+type fetchedPage struct {
+	contents      []byte
+	validators    *web.Validators
+	isNotModified bool
+}
+
+// getPage fetches source code of a page and decodes it into UTF-8.
+func (a *App) getPage(url string) (pageContents []byte, err error) {
+	var page *fetchedPage
+	page, err = a.getPageIfModified(url, nil)
+	if err != nil {
+		return nil, err
 	}
 
-	var node = htmldom.GetChildNodeByTag(domNode, htmldom.TagHtml) // -> <doctype>
-	node = htmldom.GetSiblingNodeByTag(node, htmldom.TagHtml)      // -> <html>
-	node = htmldom.GetChildNodeByTag(node, htmldom.TagHead)        // -> <head>
-	node = htmldom.GetSiblingNodeByTag(node, htmldom.TagBody)      // -> <body>
-	node = htmldom.GetChildNodeByTagAndId(node, htmldom.TagDiv, "body_container")
-	node = htmldom.GetChildNodeByTagAndId(node, htmldom.TagDiv, "page_container")
-	node = htmldom.GetChildNodeByTagAndId(node, htmldom.TagDiv, "page_content")
-	node = htmldom.GetChildNodeByTag(node, htmldom.TagTable)                            // <table>
-	node = htmldom.GetChildNodeByTag(node, htmldom.TagTbody)                            // <tbody>
-	node = htmldom.GetChildNodeByTag(node, htmldom.TagTr)                               // <tr>
-	node = htmldom.GetChildNodeByTagAndId(node, htmldom.TagTd, "main_content")          // <td id="main_content">
-	node = htmldom.GetChildNodeByTag(node, htmldom.TagDiv)                              // <div id="main_content_wrap">
-	node = htmldom.GetChildNodeByTagAndClass(node, htmldom.TagTable, "forumline forum") // <table class="forumline forum">
-	node = htmldom.GetChildNodeByTag(node, htmldom.TagTbody)                            // <tbody>
-	node = htmldom.GetChildNodeByTag(node, htmldom.TagTr)                               // <tr>
+	return page.contents, nil
+}
 
-	for {
-		if htmldom.NodeHasAttribute(node, AttributeId) {
-			break
-		}
+// getPageIfModified fetches source code of a page, unless it has not been
+// modified since the version identified by validators, and decodes it into
+// UTF-8. When a captcha is requested, the page is fetched again after a pause.
+// When the session has expired, the crawler logs in again, if it is able to.
+func (a *App) getPageIfModified(url string, validators *web.Validators) (page *fetchedPage, err error) {
+	if a.isOffline() {
+		return nil, fmt.Errorf(ErrPageIsNotFetchedOffline, url)
+	}
 
-		node = htmldom.GetSiblingNodeByTag(node, htmldom.TagTr) // next <tr>
-		if node == nil {
-			a.printNodeDebugInfo(node)
-			return nil, errors.New(ErrTrWithIdIsNotFound)
-		}
+	err = a.ensureSession(url)
+	if err != nil {
+		return nil, err
 	}
 
-	topics = make([]*models.Topic, 0)
-	var topic *models.Topic
-	for {
-		topic = &models.Topic{
-			ForumId: forumId,
+	isReloggedIn := false
+	for attempt := 1; ; attempt++ {
+		requestedAt := time.Now()
+		page, err = a.fetchPage(url, validators)
+		if err == nil {
+			return page, nil
 		}
 
-		var id string
-		var ok bool
-		id, ok = htmldom.GetNodeAttributeValue(node, AttributeId)
-		if ok {
-			topic.Id, err = a.getNumberFromId(id)
+		var nle *web.NotLoggedInError
+		if errors.As(err, &nle) && a.hasAuth() && !isReloggedIn {
+			log.Println(fmt.Sprintf("Session has expired: %v", url))
+			err = a.relogin(requestedAt)
 			if err != nil {
 				return nil, err
 			}
 
-			topic.Name, err = a.getTopicName(node, topic.Id)
-			if err != nil {
-				return nil, err
-			}
-
-			topics = append(topics, topic)
+			isReloggedIn = true
+			continue
 		}
 
-		// Next <tr>.
-		node = htmldom.GetSiblingNodeByTag(node, htmldom.TagTr)
-		if node == nil {
-			break
+		var ce *web.CaptchaError
+		if !errors.As(err, &ce) || (attempt >= CaptchaMaxAttempts) {
+			return nil, err
 		}
-	}
 
-	return topics, nil
-}
-
-func (a *App) getNumberFromId(idStr string) (n uint, err error) {
-	parts := strings.Split(idStr, "-")
-	if len(parts) != 2 {
-		return 0, fmt.Errorf(ErrNoNumberInId, idStr)
+		captchaWait := a.getCaptchaWait()
+		log.Println(fmt.Sprintf("Captcha is requested. Waiting for %v.", captchaWait))
+		time.Sleep(captchaWait)
 	}
-
-	return number.ParseUint(parts[1])
 }
 
-// getTopicName searches for the topic name in a piece of an HTML code.
-// N.B. 'trNode' argument must be preserved, i.e. it is read-only !
-func (a *App) getTopicName(trNode *html.Node, topicId uint) (topicName string, err error) {
-	n := htmldom.GetChildNodeByTagAndClass(trNode, htmldom.TagTd, "tt")  // <td style="padding: 3px 5px 3px 3px;" class="tt">
-	n = htmldom.GetChildNodeByTagAndClass(n, htmldom.TagDiv, "torTopic") // <div class="torTopic">
-	n = htmldom.GetChildNodeByTag(n, htmldom.TagA)                       // <a>
-
-	for {
-		if htmldom.NodeHasAttribute(n, AttributeId) {
-			break
-		}
+// fetchPage fetches source code of a page, decodes it into UTF-8 and checks
+// whether it is a normal page. Validators make the request conditional.
+func (a *App) fetchPage(url string, validators *web.Validators) (page *fetchedPage, err error) {
+	var resp *web.Response
+	resp, err = a.Client.GetIfModified(url, validators)
+	if err != nil {
+		return nil, err
+	}
 
-		n = htmldom.GetSiblingNodeByTag(n, htmldom.TagA) // next <a>
-		if n == nil {
-			return "", errors.New(ErrAWithIdIsNotFound)
-		}
+	if resp.IsNotModified() {
+		return &fetchedPage{isNotModified: true}, nil
 	}
 
-	// Integrity Check.
-	idStr, ok := htmldom.GetNodeAttributeValue(n, AttributeId)
-	if !ok {
-		return "", errors.New(ErrIdAttributeIsNotFound)
+	page = &fetchedPage{
+		validators: resp.Validators(),
 	}
-	var id uint
-	id, err = a.getNumberFromId(idStr)
+
+	page.contents, err = a.decodeBytes(resp.Body, resp.Header.Get(web.HttpHeaderContentType))
 	if err != nil {
-		return "", err
+		return nil, err
 	}
-	if id != topicId {
-		return "", fmt.Errorf(ErrTopicIdMismatch, topicId, id)
+
+	err = web.CheckPage(url, page.contents, a.expectsLogin())
+	if err != nil {
+		return nil, err
 	}
 
-	var href string
-	href, ok = htmldom.GetNodeAttributeValue(n, AttributeHref)
-	if !ok {
-		return "", errors.New(ErrHrefAttributeIsNotFound)
+	return page, nil
+}
+
+// getCaptchaWait returns a pause made when a captcha is requested.
+func (a *App) getCaptchaWait() time.Duration {
+	captchaWaitSec := float64(DefaultCaptchaWaitSec)
+	if (a.Settings.Http != nil) && (a.Settings.Http.CaptchaWaitSec > 0) {
+		captchaWaitSec = a.Settings.Http.CaptchaWaitSec
 	}
-	if !strings.Contains(href, strconv.FormatUint(uint64(topicId), 10)) {
-		return "", fmt.Errorf(ErrHrefMismatch, topicId, href)
+
+	return time.Duration(float64(time.Second) * captchaWaitSec)
+}
+
+// isForumSkippable checks whether an error of a forum allows to continue with
+// other forums. In offline mode, forums which are not cached are skipped.
+func isForumSkippable(forumId uint, err error) bool {
+	var fe *web.ForumDoesNotExistError
+	var pe *cache.PageIsNotCachedError
+	if !errors.As(err, &fe) && !errors.As(err, &pe) {
+		return false
 	}
 
-	topicName, err = htmldom.GetInnerHtml(n)
+	fmt.Println()
+	log.Println(fmt.Sprintf("Forum ID=%v is skipped: %v", forumId, err.Error()))
+	return true
+}
+
+// decodeBytes decodes a page into UTF-8. In the automatic mode, the encoding
+// is detected by the 'Content-Type' header and by the page itself.
+func (a *App) decodeBytes(dataInput []byte, contentType string) (utfOutput []byte, err error) {
+	var enc encoding.Encoding
+	enc, err = a.getPageEncoding()
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
-	return clearName(topicName), nil
+	return web.DecodePage(dataInput, contentType, enc)
 }
 
-func (a *App) getHtmlNodeInnerHtmlUint(n *html.Node) (num uint, err error) {
-	var text string
-	text, err = htmldom.GetInnerHtml(n)
+// getPageEncoding returns the encoding of pages set in settings. Nil is
+// returned in the automatic mode.
+func (a *App) getPageEncoding() (enc encoding.Encoding, err error) {
+	if a.Settings.PageEncoding == models.PageEncoding_Auto {
+		return nil, nil
+	}
+
+	return web.LookupEncoding(a.Settings.PageEncoding)
+}
+
+// findForumPagesCount searches for the count of pages in the source code of a
+// page using the site profile. Pages of Discourse are read as JSON.
+func (a *App) findForumPagesCount(forumId uint, pageContents []byte) (pageCount uint, err error) {
+	if a.isDiscourse() {
+		return a.findDiscoursePagesCount(pageContents)
+	}
+
+	var domNode *html.Node
+	domNode, err = parsePage(pageContents)
 	if err != nil {
 		return 0, err
 	}
 
-	return number.ParseUint(text)
+	return a.Profile.FindPageCount(domNode)
 }
 
-func (a *App) printNodeDebugInfo(n *html.Node) {
-	text, err := htmldom.GetOuterHtml(n)
+// findForumTopics searches for topics in the source code of a forum page
+// using the site profile. Pages of Discourse are read as JSON.
+func (a *App) findForumTopics(forumId uint, pageContents []byte) (topics []*models.Topic, err error) {
+	if a.isDiscourse() {
+		return a.findDiscourseTopics(forumId, pageContents)
+	}
+
+	var domNode *html.Node
+	domNode, err = parsePage(pageContents)
 	if err != nil {
-		log.Println(fmt.Sprintf("Node: %+v, Error: %v.", n, err))
-	} else {
-		log.Println(text)
+		return nil, err
+	}
+
+	var pageTopics []*profile.Topic
+	pageTopics, err = a.Profile.FindTopics(domNode)
+	if err != nil {
+		return nil, err
+	}
+
+	topics = make([]*models.Topic, 0, len(pageTopics))
+	seenAt := getCrawlTime()
+	for _, pt := range pageTopics {
+		topics = append(topics, &models.Topic{
+			Id:          pt.Id,
+			Name:        pt.Name,
+			ForumId:     forumId,
+			FirstSeenAt: seenAt,
+			LastSeenAt:  seenAt,
+		})
 	}
-}
 
-func (a *App) sleepBetweenTopicPages() {
-	time.Sleep(time.Duration(float64(time.Second) * a.Settings.ForumTopicsPageDelaySec))
+	return topics, nil
 }
 
-func clearName(dirtyName string) (cleanName string) {
-	return remove4BytedRunes(html.UnescapeString(strings.ReplaceAll(dirtyName, TagWbr, "")))
+func parsePage(pageContents []byte) (domNode *html.Node, err error) {
+	domNode, err = html.Parse(strings.NewReader(string(pageContents)))
+	if err != nil {
+		return nil, err
+	}
+	if domNode == nil {
+		return nil, errors.New(ErrDomNodeIsNotFound)
+	}
+
+	return domNode, nil
 }
 
-// Unfortunately, UTF-8 encoding in MySQL supports runes having 3 bytes maximum.
-// This is why the limit for indices is 3072 bytes (1024 x 3).
-// Support for full-length (4 byte) UTF-8 is planned for future.
-// Until that time, we are removing unsupported symbols from strings.
-func remove4BytedRunes(s string) string {
-	var sb strings.Builder
-	runes := []rune(s)
-	for _, r := range runes {
-		if getRuneSize(r) < 4 {
-			sb.WriteRune(r)
-		} else {
-			log.Println(fmt.Sprintf("unsupported rune was removed: %v", string(r)))
+// splitTopicsIntoBatches splits topics into batches ordered by topic IDs.
+func splitTopicsIntoBatches(topics map[uint]*models.Topic, batchSize uint) (batches []map[uint]*models.Topic) {
+	if batchSize == 0 {
+		batchSize = db.DefaultBatchSize
+	}
+
+	ids := make([]uint, 0, len(topics))
+	for id := range topics {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	batches = make([]map[uint]*models.Topic, 0, uint(len(ids))/batchSize+1)
+	var batch map[uint]*models.Topic
+	for i, id := range ids {
+		if uint(i)%batchSize == 0 {
+			batch = make(map[uint]*models.Topic, batchSize)
+			batches = append(batches, batch)
 		}
+		batch[id] = topics[id]
 	}
-	return sb.String()
+
+	return batches
 }
 
-func getRuneSize(r rune) int {
-	return len([]byte(string(r)))
+// getCrawlTime returns current time in the form stored in the database.
+func getCrawlTime() time.Time {
+	return time.Now().UTC().Truncate(time.Second)
 }
@@ -0,0 +1,192 @@
+package a
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	neturl "net/url"
+	"os"
+	"time"
+
+	"github.com/vault-thirteen/Forum-Crawler/src/models"
+	"github.com/vault-thirteen/Forum-Crawler/src/pkg/web"
+	"golang.org/x/text/encoding"
+)
+
+const (
+	ErrLoginUrlIsNotSet     = "login URL is not set"
+	ErrCredentialsAreNotSet = "credentials are not set"
+	ErrLoginFailed          = "login failed: %v"
+)
+
+const (
+	DefaultUsernameField = "login_username"
+	DefaultPasswordField = "login_password"
+)
+
+// hasAuth checks whether the crawler logs in by itself.
+func (a *App) hasAuth() bool {
+	return a.Settings.Auth != nil
+}
+
+// expectsLogin checks whether pages must be shown to a logged-in user, i.e.
+// whether a login form on a page means that the session has expired.
+func (a *App) expectsLogin() bool {
+	return (len(a.Settings.Cookie) > 0) || a.hasAuth()
+}
+
+// ensureSession logs in when there is no session cookie for the URL, e.g. at
+// the first run.
+func (a *App) ensureSession(url string) (err error) {
+	if !a.hasAuth() || a.Jar.HasCookies(url) {
+		return nil
+	}
+
+	return a.relogin(time.Time{})
+}
+
+// relogin logs in unless somebody has already logged in after the moment
+// when a logged-out page was requested. Workers which see a logged-out page
+// at the same time log in only once.
+func (a *App) relogin(requestedAt time.Time) (err error) {
+	a.loginLock.Lock()
+	defer a.loginLock.Unlock()
+
+	if !a.loggedInAt.IsZero() && a.loggedInAt.After(requestedAt) {
+		return nil
+	}
+
+	err = a.login()
+	if err != nil {
+		return err
+	}
+
+	a.loggedInAt = time.Now()
+	return nil
+}
+
+// login posts the login form. Session cookies set by the server are kept in
+// the cookie jar.
+func (a *App) login() (err error) {
+	auth := a.Settings.Auth
+	if len(auth.LoginUrl) == 0 {
+		return errors.New(ErrLoginUrlIsNotSet)
+	}
+
+	var credentials *models.Credentials
+	credentials, err = a.getCredentials()
+	if err != nil {
+		return err
+	}
+
+	form := neturl.Values{}
+	for name, value := range auth.ExtraFields {
+		form.Set(name, value)
+	}
+	form.Set(fieldOrDefault(auth.UsernameField, DefaultUsernameField), credentials.Username)
+	form.Set(fieldOrDefault(auth.PasswordField, DefaultPasswordField), credentials.Password)
+
+	form, err = a.encodeForm(form)
+	if err != nil {
+		return err
+	}
+
+	log.Println(fmt.Sprintf("Logging in as %v.", credentials.Username))
+
+	var resp *web.Response
+	resp, err = a.Client.PostForm(auth.LoginUrl, form)
+	if err != nil {
+		return err
+	}
+
+	var pageContents []byte
+	pageContents, err = a.decodeBytes(resp.Body, resp.Header.Get(web.HttpHeaderContentType))
+	if err != nil {
+		return err
+	}
+
+	// A failed login shows the login form again.
+	err = web.CheckPage(auth.LoginUrl, pageContents, true)
+	if err != nil {
+		var nle *web.NotLoggedInError
+		if errors.As(err, &nle) {
+			return fmt.Errorf(ErrLoginFailed, credentials.Username)
+		}
+		return err
+	}
+
+	if !a.Jar.HasCookies(auth.LoginUrl) {
+		return fmt.Errorf(ErrLoginFailed, credentials.Username)
+	}
+
+	return nil
+}
+
+// getCredentials returns credentials set in settings or read from the
+// credentials file.
+func (a *App) getCredentials() (credentials *models.Credentials, err error) {
+	auth := a.Settings.Auth
+
+	credentials = &models.Credentials{
+		Username: auth.Username,
+		Password: auth.Password,
+	}
+
+	if (len(credentials.Username) == 0) && (len(auth.CredentialsFile) > 0) {
+		var buf []byte
+		buf, err = os.ReadFile(auth.CredentialsFile)
+		if err != nil {
+			return nil, err
+		}
+
+		err = json.Unmarshal(buf, credentials)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if len(credentials.Username) == 0 {
+		return nil, errors.New(ErrCredentialsAreNotSet)
+	}
+
+	return credentials, nil
+}
+
+// encodeForm encodes values of a form into the encoding of pages, as forums
+// expect forms in the encoding of their pages. In the automatic mode, forms
+// are sent in UTF-8.
+func (a *App) encodeForm(form neturl.Values) (encodedForm neturl.Values, err error) {
+	var enc encoding.Encoding
+	enc, err = a.getPageEncoding()
+	if err != nil {
+		return nil, err
+	}
+	if enc == nil {
+		return form, nil
+	}
+
+	encoder := enc.NewEncoder()
+	encodedForm = make(neturl.Values, len(form))
+	for name, values := range form {
+		for _, value := range values {
+			var encodedValue string
+			encodedValue, err = encoder.String(value)
+			if err != nil {
+				return nil, err
+			}
+
+			encodedForm.Add(name, encodedValue)
+		}
+	}
+
+	return encodedForm, nil
+}
+
+func fieldOrDefault(field string, defaultField string) string {
+	if len(field) == 0 {
+		return defaultField
+	}
+
+	return field
+}
@@ -0,0 +1,201 @@
+package a
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/vault-thirteen/Forum-Crawler/src/models"
+	"github.com/vault-thirteen/Forum-Crawler/src/pkg/CLIArguments"
+	"github.com/vault-thirteen/Forum-Crawler/src/pkg/db"
+)
+
+const (
+	CrawlRunIdTimeFormat = "20060102_150405.000000"
+)
+
+// checkpoints record progress of a crawl run, so that an interrupted run is
+// resumed by the next run of the same kind. Checkpoints are written only by
+// the writer. Nil checkpoints, i.e. of a storage without crawl states, do
+// nothing.
+type checkpoints struct {
+	store  db.CrawlStateStore
+	run    *models.CrawlRun
+	states map[uint]*models.CrawlState
+}
+
+// startCrawlRun resumes the last unfinished run of the kind or starts a new
+// run. When the 'new_run' parameter is set, the unfinished run is abandoned
+// and a new run is started.
+func (a *App) startCrawlRun(kind string) (cp *checkpoints, err error) {
+	store, ok := db.FindCrawlStateStore(a.Db)
+	if !ok {
+		return nil, nil
+	}
+
+	var isNewRun bool
+	if a.CLIArgs.HasParameter(cli.Parameter_NewRun) {
+		isNewRun, err = a.CLIArgs.GetNewRun()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	cp = &checkpoints{
+		store: store,
+	}
+
+	cp.run, err = store.GetUnfinishedCrawlRun(kind)
+	if err != nil {
+		return nil, err
+	}
+
+	if (cp.run != nil) && isNewRun {
+		cp.run.Status = models.CrawlStatus_Abandoned
+		cp.run.UpdatedAt = getCrawlTime()
+
+		err = store.UpdateCrawlRun(cp.run)
+		if err != nil {
+			return nil, err
+		}
+
+		log.Println(fmt.Sprintf("Abandoning crawl run %v started at %v.", cp.run.Id, cp.run.StartedAt))
+		cp.run = nil
+	}
+
+	if cp.run != nil {
+		cp.states, err = store.GetCrawlStates(cp.run.Id)
+		if err != nil {
+			return nil, err
+		}
+
+		log.Println(fmt.Sprintf("Resuming crawl run %v started at %v.", cp.run.Id, cp.run.StartedAt))
+		return cp, nil
+	}
+
+	now := getCrawlTime()
+	cp.run = &models.CrawlRun{
+		Id:        time.Now().UTC().Format(CrawlRunIdTimeFormat),
+		Kind:      kind,
+		Status:    models.CrawlStatus_InProgress,
+		StartedAt: now,
+		UpdatedAt: now,
+	}
+	cp.states = make(map[uint]*models.CrawlState)
+
+	err = store.InsertCrawlRun(cp.run)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Println(fmt.Sprintf("Starting crawl run %v.", cp.run.Id))
+	return cp, nil
+}
+
+// getCrawlKind returns the kind of a crawl run, i.e. the action, the object,
+// the selection of forums and the offline mode. Runs of the same kind resume
+// each other. The 'start_forum_id' parameter is not a part of the kind, so
+// that a run may be resumed from another forum.
+func (a *App) getCrawlKind() string {
+	kind := a.CLIArgs.Action + " " + a.CLIArgs.Object
+
+	if a.CLIArgs.HasParameter(cli.Parameter_CategoryId) {
+		categoryId, _ := a.CLIArgs.GetCategoryId()
+		kind += fmt.Sprintf(" %v=%v", cli.Parameter_CategoryId, categoryId)
+	}
+	if a.CLIArgs.HasParameter(cli.Parameter_RootForumId) {
+		rootForumId, _ := a.CLIArgs.GetRootForumId()
+		kind += fmt.Sprintf(" %v=%v", cli.Parameter_RootForumId, rootForumId)
+	}
+	if a.isOffline() {
+		kind += " " + cli.Parameter_Offline
+	}
+
+	return kind
+}
+
+// planJobs creates jobs for forums which are not finished by the run.
+// Unfinished forums are continued from the page following the last saved
+// page, keeping the time when their crawl was started.
+func (cp *checkpoints) planJobs(forums []*models.Forum) (jobs []*forumJob) {
+	jobs = newForumJobs(forums)
+	if cp == nil {
+		return jobs
+	}
+
+	planned := make([]*forumJob, 0, len(jobs))
+	for _, job := range jobs {
+		state, ok := cp.states[job.forum.ID]
+		if ok {
+			if state.Status != models.CrawlStatus_InProgress {
+				continue
+			}
+
+			job.firstPage = state.LastPage + 1
+			job.crawlStartedAt = state.StartedAt
+		}
+
+		job.idx = len(planned)
+		planned = append(planned, job)
+	}
+
+	if len(planned) < len(jobs) {
+		log.Println(fmt.Sprintf("Forums finished by the run: %v.", len(jobs)-len(planned)))
+	}
+
+	return planned
+}
+
+// savePage records that a page of a forum is saved.
+func (cp *checkpoints) savePage(job *forumJob, page *forumPage) (err error) {
+	if cp == nil {
+		return nil
+	}
+
+	state := cp.getState(job)
+	state.LastPage = page.pageNum
+	state.PageCount = page.pageCount
+	state.UpdatedAt = getCrawlTime()
+
+	return cp.store.SaveCrawlState(state)
+}
+
+// finishForum records that a forum is finished.
+func (cp *checkpoints) finishForum(job *forumJob, status string) (err error) {
+	if cp == nil {
+		return nil
+	}
+
+	state := cp.getState(job)
+	state.Status = status
+	state.UpdatedAt = getCrawlTime()
+
+	return cp.store.SaveCrawlState(state)
+}
+
+// finishRun records that all forums of the run are finished.
+func (cp *checkpoints) finishRun() (err error) {
+	if cp == nil {
+		return nil
+	}
+
+	cp.run.Status = models.CrawlStatus_Completed
+	cp.run.UpdatedAt = getCrawlTime()
+
+	return cp.store.UpdateCrawlRun(cp.run)
+}
+
+func (cp *checkpoints) getState(job *forumJob) (state *models.CrawlState) {
+	state, ok := cp.states[job.forum.ID]
+	if !ok {
+		state = &models.CrawlState{
+			RunId:     cp.run.Id,
+			ForumId:   job.forum.ID,
+			Status:    models.CrawlStatus_InProgress,
+			StartedAt: job.crawlStartedAt,
+		}
+		cp.states[job.forum.ID] = state
+	}
+
+	return state
+}
@@ -0,0 +1,155 @@
+package a
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/vault-thirteen/Forum-Crawler/src/models"
+	"github.com/vault-thirteen/Forum-Crawler/src/pkg/discourse"
+	"github.com/vault-thirteen/Forum-Crawler/src/pkg/web"
+)
+
+// isDiscourse checks whether forums are categories of a Discourse board read
+// through its JSON API.
+func (a *App) isDiscourse() bool {
+	return a.Settings.Discourse != nil
+}
+
+// getDiscourseCategories returns categories of the board. Categories are
+// fetched once and are shared by workers.
+func (a *App) getDiscourseCategories() (categories *discourse.Categories, err error) {
+	a.discourseLock.Lock()
+	defer a.discourseLock.Unlock()
+
+	if a.discourseCategories != nil {
+		return a.discourseCategories, nil
+	}
+
+	var siteUrl string
+	siteUrl, err = discourse.GetSiteUrl(a.Settings.Discourse.BaseUrl)
+	if err != nil {
+		return nil, err
+	}
+
+	var data []byte
+	data, err = a.getPage(siteUrl)
+	if err != nil {
+		return nil, err
+	}
+
+	a.discourseCategories, err = discourse.ParseCategories(data)
+	if err != nil {
+		return nil, err
+	}
+
+	return a.discourseCategories, nil
+}
+
+// getDiscourseForums maps categories of the board to forums. Top-level
+// categories are both forums and categories of forums, subcategories are
+// sub-forums.
+func (a *App) getDiscourseForums() (forums []*models.Forum, err error) {
+	var categories *discourse.Categories
+	categories, err = a.getDiscourseCategories()
+	if err != nil {
+		return nil, err
+	}
+
+	forums = make([]*models.Forum, 0, len(categories.List))
+	for _, cat := range categories.List {
+		var root *discourse.Category
+		root, err = categories.GetRoot(cat.Id)
+		if err != nil {
+			return nil, err
+		}
+
+		forums = append(forums, &models.Forum{
+			ID:           cat.Id,
+			Name:         cat.Name,
+			CategoryId:   root.Id,
+			CategoryName: root.Name,
+			ParentId:     cat.ParentCategoryId,
+			Order:        uint(len(forums) + 1),
+		})
+	}
+
+	err = setForumDepths(forums)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, forum := range forums {
+		if forum.ParentId == 0 {
+			fmt.Println(fmt.Sprintf("Category ID=%v: %v", forum.ID, forum.Name))
+		}
+		fmt.Println(fmt.Sprintf("%vForum ID=%v: %v", strings.Repeat("\t", int(forum.Depth)+1), forum.ID, forum.Name))
+	}
+	return forums, nil
+}
+
+// getDiscoursePageUrl returns the address of a page of a category. Pages of
+// Discourse are counted from zero. Categories which are not visible on the
+// board are reported as forums which do not exist.
+func (a *App) getDiscoursePageUrl(forumId uint, startItemIdx uint) (url string, err error) {
+	var categories *discourse.Categories
+	categories, err = a.getDiscourseCategories()
+	if err != nil {
+		return "", err
+	}
+
+	_, err = categories.Get(forumId)
+	if err != nil {
+		return "", &web.ForumDoesNotExistError{Url: err.Error()}
+	}
+
+	return categories.GetPageUrl(a.Settings.Discourse.BaseUrl, forumId, startItemIdx/a.Settings.TopicsPerPage)
+}
+
+// findDiscoursePagesCount returns the number of pages known from a page of a
+// category. Discourse does not tell the count of pages, so that the count is
+// the number of the next page, or 1 on the last page.
+func (a *App) findDiscoursePagesCount(pageContents []byte) (pageCount uint, err error) {
+	var tl *discourse.TopicList
+	tl, err = discourse.ParseTopicList(pageContents)
+	if err != nil {
+		return 0, err
+	}
+
+	nextPageIdx, ok, err := tl.GetNextPage()
+	if err != nil {
+		return 0, err
+	}
+	if !ok {
+		return 1, nil
+	}
+
+	return nextPageIdx + 1, nil
+}
+
+// findDiscourseTopics reads topics from a page of a category. Topics of
+// subcategories are skipped, as they are read with their own categories.
+func (a *App) findDiscourseTopics(forumId uint, pageContents []byte) (topics []*models.Topic, err error) {
+	var tl *discourse.TopicList
+	tl, err = discourse.ParseTopicList(pageContents)
+	if err != nil {
+		return nil, err
+	}
+
+	topics = make([]*models.Topic, 0, len(tl.Topics))
+	seenAt := getCrawlTime()
+	for _, t := range tl.Topics {
+		if t.CategoryId != forumId {
+			continue
+		}
+
+		topics = append(topics, &models.Topic{
+			Id:          t.Id,
+			Name:        t.Title,
+			ForumId:     forumId,
+			FirstSeenAt: seenAt,
+			LastSeenAt:  seenAt,
+		})
+	}
+
+	return topics, nil
+}
@@ -0,0 +1,176 @@
+package a
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/vault-thirteen/Forum-Crawler/src/models"
+	"github.com/vault-thirteen/Forum-Crawler/src/pkg/CLIArguments"
+	"github.com/vault-thirteen/Forum-Crawler/src/pkg/profile"
+	ae "github.com/vault-thirteen/auxie/errors"
+	"golang.org/x/net/html"
+)
+
+const (
+	ErrForumIndexUrlIsNotSet = "forum index URL is not set"
+	ErrNoForumsOnIndexPage   = "no forums are found on the index page"
+)
+
+// discoverForums reads the list of forums from the forum index page, or
+// categories of a Discourse board, and saves it into the database and,
+// optionally, into the forums file.
+func (a *App) discoverForums() (err error) {
+	log.Println("Discovering forums")
+
+	// The forums file is not written unless it is asked for.
+	var writeCsv bool
+	if a.CLIArgs.HasParameter(cli.Parameter_WriteCsv) {
+		writeCsv, err = a.CLIArgs.GetWriteCsv()
+		if err != nil {
+			return err
+		}
+	}
+
+	var forums []*models.Forum
+	if a.isDiscourse() {
+		forums, err = a.getDiscourseForums()
+	} else {
+		forums, err = a.getIndexForums()
+	}
+	if err != nil {
+		return err
+	}
+
+	log.Println(fmt.Sprintf("Forums found: %v.", len(forums)))
+
+	err = a.saveForums(forums)
+	if err != nil {
+		return err
+	}
+
+	if writeCsv {
+		err = a.writeForumsFile(a.Settings.ForumsFile, forums)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// getIndexForums reads forums from the forum index page.
+func (a *App) getIndexForums() (forums []*models.Forum, err error) {
+	if len(a.Settings.ForumIndexUrl) == 0 {
+		return nil, errors.New(ErrForumIndexUrlIsNotSet)
+	}
+
+	var pageSrc []byte
+	pageSrc, err = a.getPage(a.Settings.ForumIndexUrl)
+	if err != nil {
+		return nil, err
+	}
+
+	return a.findIndexForums(pageSrc)
+}
+
+// findIndexForums searches for categories and forums in the source code of
+// the forum index page using the site profile. Links to categories and forums
+// are read in the order of the document, so that each forum follows its
+// category. Links to sub-forums belong to the last top-level forum.
+func (a *App) findIndexForums(pageContents []byte) (forums []*models.Forum, err error) {
+	var domNode *html.Node
+	domNode, err = html.Parse(strings.NewReader(string(pageContents)))
+	if err != nil {
+		return nil, err
+	}
+	if domNode == nil {
+		return nil, errors.New(ErrDomNodeIsNotFound)
+	}
+
+	var links []*profile.IndexLink
+	links, err = a.Profile.FindIndexLinks(domNode)
+	if err != nil {
+		return nil, err
+	}
+
+	forums = make([]*models.Forum, 0)
+	knownForums := make(map[uint]bool)
+	var categoryId uint
+	var categoryName string
+	var parentForum *models.Forum
+
+	for _, link := range links {
+		if link.IsCategory {
+			categoryId, categoryName = link.Id, link.Name
+			parentForum = nil
+			fmt.Println(fmt.Sprintf("Category ID=%v: %v", link.Id, categoryName))
+			continue
+		}
+
+		if knownForums[link.Id] {
+			continue
+		}
+		knownForums[link.Id] = true
+
+		forum := &models.Forum{
+			ID:           link.Id,
+			Name:         link.Name,
+			CategoryId:   categoryId,
+			CategoryName: categoryName,
+			Order:        uint(len(forums) + 1),
+		}
+
+		if (parentForum != nil) && link.IsSubForum {
+			forum.ParentId = parentForum.ID
+			forum.Depth = parentForum.Depth + 1
+		} else {
+			parentForum = forum
+		}
+
+		forums = append(forums, forum)
+		fmt.Println(fmt.Sprintf("%vForum ID=%v: %v", strings.Repeat("\t", int(forum.Depth)+1), forum.ID, forum.Name))
+	}
+
+	if len(forums) == 0 {
+		return nil, errors.New(ErrNoForumsOnIndexPage)
+	}
+
+	return forums, nil
+}
+
+// writeForumsFile writes forums into a file having the extended CSV format.
+func (a *App) writeForumsFile(forumsFile string, forums []*models.Forum) (err error) {
+	var f *os.File
+	f, err = os.Create(forumsFile)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		derr := f.Close()
+		if derr != nil {
+			err = ae.Combine(err, derr)
+		}
+	}()
+
+	csvWriter := csv.NewWriter(f)
+	for _, forum := range forums {
+		err = csvWriter.Write([]string{
+			strconv.FormatUint(uint64(forum.ID), 10),
+			forum.Name,
+			strconv.FormatUint(uint64(forum.CategoryId), 10),
+			forum.CategoryName,
+			strconv.FormatUint(uint64(forum.ParentId), 10),
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	csvWriter.Flush()
+	return csvWriter.Error()
+}
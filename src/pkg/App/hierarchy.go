@@ -0,0 +1,138 @@
+package a
+
+import (
+	"fmt"
+
+	"github.com/vault-thirteen/Forum-Crawler/src/models"
+	"github.com/vault-thirteen/Forum-Crawler/src/pkg/CLIArguments"
+	"github.com/vault-thirteen/auxie/number"
+)
+
+// selectForums selects forums for crawling using command line parameters:
+//   - 'category_id' selects all forums of a category;
+//   - 'root_forum_id' selects a forum with all its sub-forums;
+//   - otherwise, 'start_forum_id' selects all forums starting from the
+//     specified one, or all forums if it is zero.
+func (a *App) selectForums(forums []*models.Forum) (selected []*models.Forum, err error) {
+	if a.CLIArgs.HasParameter(cli.Parameter_CategoryId) {
+		var categoryId uint
+		categoryId, err = a.CLIArgs.GetCategoryId()
+		if err != nil {
+			return nil, err
+		}
+
+		return selectCategoryForums(forums, categoryId)
+	}
+
+	if a.CLIArgs.HasParameter(cli.Parameter_RootForumId) {
+		var rootForumId uint
+		rootForumId, err = a.CLIArgs.GetRootForumId()
+		if err != nil {
+			return nil, err
+		}
+
+		return selectForumSubtree(forums, rootForumId)
+	}
+
+	var startForumId uint
+	startForumId, err = a.CLIArgs.GetStartForumId()
+	if err != nil {
+		return nil, err
+	}
+
+	return selectForumsFrom(forums, startForumId)
+}
+
+func selectCategoryForums(forums []*models.Forum, categoryId uint) (selected []*models.Forum, err error) {
+	selected = make([]*models.Forum, 0)
+	for _, forum := range forums {
+		if forum.CategoryId == categoryId {
+			selected = append(selected, forum)
+		}
+	}
+
+	if len(selected) == 0 {
+		return nil, fmt.Errorf(ErrNoForumsInCategory, categoryId)
+	}
+
+	return selected, nil
+}
+
+// selectForumSubtree selects the root forum and all its descendants keeping
+// the order of the list.
+func selectForumSubtree(forums []*models.Forum, rootForumId uint) (selected []*models.Forum, err error) {
+	parents := make(map[uint]uint, len(forums))
+	for _, forum := range forums {
+		parents[forum.ID] = forum.ParentId
+	}
+
+	_, rootExists := parents[rootForumId]
+	if !rootExists {
+		return nil, fmt.Errorf(ErrForumIsNotFound, rootForumId)
+	}
+
+	selected = make([]*models.Forum, 0)
+	for _, forum := range forums {
+		// Depths are checked, so there are no cycles.
+		for id := forum.ID; id != 0; id = parents[id] {
+			if id == rootForumId {
+				selected = append(selected, forum)
+				break
+			}
+		}
+	}
+
+	return selected, nil
+}
+
+// selectForumsFrom skips forums until the start forum is found. Zero start
+// forum ID selects all forums.
+func selectForumsFrom(forums []*models.Forum, startForumId uint) (selected []*models.Forum, err error) {
+	if startForumId == 0 {
+		return forums, nil
+	}
+
+	for i, forum := range forums {
+		if forum.ID == startForumId {
+			return forums[i:], nil
+		}
+	}
+
+	return nil, fmt.Errorf(ErrForumIsNotFound, startForumId)
+}
+
+// setForumDepths calculates depths of forums using their parents.
+func setForumDepths(forums []*models.Forum) (err error) {
+	byId := make(map[uint]*models.Forum, len(forums))
+	for _, forum := range forums {
+		byId[forum.ID] = forum
+	}
+
+	for _, forum := range forums {
+		forum.Depth = 0
+		for parentId := forum.ParentId; parentId != 0; {
+			parent, ok := byId[parentId]
+			if !ok {
+				return fmt.Errorf(ErrUnknownParentForum, parentId)
+			}
+
+			forum.Depth++
+			if forum.Depth > uint(len(forums)) {
+				return fmt.Errorf(ErrForumCycle, forum.ID)
+			}
+
+			parentId = parent.ParentId
+		}
+	}
+
+	return nil
+}
+
+// parseOptionalUint parses a number where an empty string means zero.
+func parseOptionalUint(s string) (n uint, err error) {
+	if len(s) == 0 {
+		return 0, nil
+	}
+
+	return number.ParseUint(s)
+}
@@ -0,0 +1,225 @@
+package a
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/vault-thirteen/Forum-Crawler/src/models"
+)
+
+const (
+	ErrCrawlIsStopped = "crawl is stopped"
+)
+
+const (
+	DefaultWorkersCount = 1
+
+	// Workers may run ahead of the writer by this number of forums per
+	// worker. It limits the number of finished forums waiting for the writer.
+	ForumsPerWorkerAhead = 2
+)
+
+// forumJob is a forum to be crawled. A forum resumed from a checkpoint is
+// crawled from its first page which has not been saved.
+type forumJob struct {
+	idx            int
+	forum          *models.Forum
+	firstPage      uint
+	crawlStartedAt time.Time
+}
+
+// forumPage is a portion of topics of a forum found by a worker.
+type forumPage struct {
+	pageNum   uint
+	pageCount uint
+	topics    map[uint]*models.Topic
+}
+
+// forumCrawler fetches topics of a forum writing progress into the writer and
+// passing found topics to the handler.
+type forumCrawler func(job *forumJob, progress io.Writer, onPage pageHandler) (err error)
+
+// forumSaver saves results of crawling. Its functions are called only by the
+// writer. 'savePage' is called for each portion of topics in the order in
+// which they are found writing progress into the writer of the forum, 'finish'
+// is called once per forum in the order of forums, skipped forums included.
+type forumSaver struct {
+	savePage func(job *forumJob, page *forumPage, progress io.Writer) (err error)
+	finish   func(job *forumJob, isSkipped bool) (err error)
+}
+
+// workerMessage is a message from a worker to the writer. A message without
+// a page means that the forum is finished.
+type workerMessage struct {
+	job      *forumJob
+	page     *forumPage
+	progress *bytes.Buffer
+	err      error
+}
+
+// crawlForums crawls forums by a pool of workers. Forums are fetched in
+// parallel, while topics are saved by a single writer, i.e. the calling
+// goroutine, as soon as they are found. Forums are finished in the order of
+// jobs and progress of each forum is printed when it is finished, so that the
+// output does not depend on timing.
+func (a *App) crawlForums(jobs []*forumJob, crawl forumCrawler, saver *forumSaver) (err error) {
+	workersCount := min(a.getWorkersCount(), max(len(jobs), 1))
+
+	// Single worker crawls forums one by one writing its progress directly.
+	if workersCount == 1 {
+		for _, job := range jobs {
+			startJob(job)
+			err = crawl(job, os.Stdout, func(pageNum uint, pageCount uint, topics map[uint]*models.Topic) (err error) {
+				return saver.savePage(job, &forumPage{pageNum: pageNum, pageCount: pageCount, topics: topics}, os.Stdout)
+			})
+
+			err = finishForum(job, err, saver)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	jobsChan := make(chan *forumJob)
+	messages := make(chan *workerMessage, workersCount)
+	slots := make(chan struct{}, workersCount*ForumsPerWorkerAhead)
+	done := make(chan struct{})
+	defer close(done)
+
+	// Feeder.
+	go func() {
+		defer close(jobsChan)
+		for _, job := range jobs {
+			select {
+			case slots <- struct{}{}:
+			case <-done:
+				return
+			}
+
+			select {
+			case jobsChan <- job:
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	// Workers.
+	for w := 0; w < workersCount; w++ {
+		go func() {
+			for job := range jobsChan {
+				startJob(job)
+				progress := &bytes.Buffer{}
+				crawlErr := crawl(job, progress, func(pageNum uint, pageCount uint, topics map[uint]*models.Topic) (err error) {
+					select {
+					case messages <- &workerMessage{job: job, page: &forumPage{pageNum: pageNum, pageCount: pageCount, topics: topics}}:
+						return nil
+					case <-done:
+						return errors.New(ErrCrawlIsStopped)
+					}
+				})
+
+				select {
+				case messages <- &workerMessage{job: job, progress: progress, err: crawlErr}:
+				case <-done:
+					return
+				}
+			}
+		}()
+	}
+
+	// Writer. Progress of saving is buffered apart from progress of workers
+	// and is printed after it.
+	finished := make(map[int]*workerMessage)
+	saveProgress := make(map[int]*bytes.Buffer)
+	for next := 0; next < len(jobs); {
+		msg, ok := finished[next]
+		if !ok {
+			msg = <-messages
+			if msg.page != nil {
+				_, ok = saveProgress[msg.job.idx]
+				if !ok {
+					saveProgress[msg.job.idx] = &bytes.Buffer{}
+				}
+
+				err = saver.savePage(msg.job, msg.page, saveProgress[msg.job.idx])
+				if err != nil {
+					return err
+				}
+				continue
+			}
+
+			finished[msg.job.idx] = msg
+			continue
+		}
+
+		delete(finished, next)
+		next++
+		<-slots
+
+		fmt.Print(msg.progress.String())
+		sp, ok := saveProgress[msg.job.idx]
+		if ok {
+			fmt.Print(sp.String())
+			delete(saveProgress, msg.job.idx)
+		}
+
+		err = finishForum(msg.job, msg.err, saver)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// startJob sets the time when the crawl of a forum is started, unless it is
+// resumed.
+func startJob(job *forumJob) {
+	if job.crawlStartedAt.IsZero() {
+		job.crawlStartedAt = getCrawlTime()
+	}
+}
+
+// finishForum finishes a crawled forum. Forums which do not exist are
+// skipped.
+func finishForum(job *forumJob, crawlErr error, saver *forumSaver) (err error) {
+	if crawlErr != nil {
+		if !isForumSkippable(job.forum.ID, crawlErr) {
+			return crawlErr
+		}
+
+		return saver.finish(job, true)
+	}
+
+	return saver.finish(job, false)
+}
+
+// newForumJobs creates jobs crawling forums from the first page.
+func newForumJobs(forums []*models.Forum) (jobs []*forumJob) {
+	jobs = make([]*forumJob, 0, len(forums))
+	for _, forum := range forums {
+		jobs = append(jobs, &forumJob{
+			idx:       len(jobs),
+			forum:     forum,
+			firstPage: 1,
+		})
+	}
+
+	return jobs
+}
+
+// getWorkersCount returns the number of workers crawling forums in parallel.
+func (a *App) getWorkersCount() int {
+	if a.Settings.Workers == 0 {
+		return DefaultWorkersCount
+	}
+
+	return int(a.Settings.Workers)
+}
@@ -11,15 +11,21 @@ import (
 )
 
 const (
-	ActionInit    = "init"
-	ActionRefresh = "refresh"
-	ActionUpdate  = "update"
+	ActionInit     = "init"
+	ActionRefresh  = "refresh"
+	ActionUpdate   = "update"
+	ActionMigrate  = "migrate"
+	ActionShow     = "show"
+	ActionDiscover = "discover"
 )
 
 const (
-	ObjectForums      = "forums"
-	ObjectForumTopics = "forum_topics"
-	ObjectAllTopics   = "all_topics"
+	ObjectForums       = "forums"
+	ObjectForumTopics  = "forum_topics"
+	ObjectAllTopics    = "all_topics"
+	ObjectUp           = "up"
+	ObjectStatus       = "status"
+	ObjectTopicHistory = "topic_history"
 )
 
 const (
@@ -41,6 +47,12 @@ const (
 	Parameter_StartForumId = "start_forum_id"
 	Parameter_ForumPage    = "forum_page"
 	Parameter_FirstPages   = "first_pages"
+	Parameter_TopicId      = "topic_id"
+	Parameter_WriteCsv     = "write_csv"
+	Parameter_CategoryId   = "category_id"
+	Parameter_RootForumId  = "root_forum_id"
+	Parameter_Offline      = "offline"
+	Parameter_NewRun       = "new_run"
 )
 
 type Arguments struct {
@@ -172,6 +184,56 @@ func (a *Arguments) GetForumPage() (fp uint, err error) {
 	return a.getNamedParameterValueAsUint(Parameter_ForumPage)
 }
 
+func (a *Arguments) GetTopicId() (tid uint, err error) {
+	return a.getNamedParameterValueAsUint(Parameter_TopicId)
+}
+
+func (a *Arguments) GetWriteCsv() (writeCsv bool, err error) {
+	var v uint
+	v, err = a.getNamedParameterValueAsUint(Parameter_WriteCsv)
+	if err != nil {
+		return false, err
+	}
+
+	return v != 0, nil
+}
+
+func (a *Arguments) GetCategoryId() (cid uint, err error) {
+	return a.getNamedParameterValueAsUint(Parameter_CategoryId)
+}
+
+func (a *Arguments) GetRootForumId() (fid uint, err error) {
+	return a.getNamedParameterValueAsUint(Parameter_RootForumId)
+}
+
+func (a *Arguments) GetOffline() (offline bool, err error) {
+	var v uint
+	v, err = a.getNamedParameterValueAsUint(Parameter_Offline)
+	if err != nil {
+		return false, err
+	}
+
+	return v != 0, nil
+}
+
+// GetNewRun tells whether an unfinished crawl run must be abandoned instead of
+// being resumed.
+func (a *Arguments) GetNewRun() (newRun bool, err error) {
+	var v uint
+	v, err = a.getNamedParameterValueAsUint(Parameter_NewRun)
+	if err != nil {
+		return false, err
+	}
+
+	return v != 0, nil
+}
+
+// HasParameter checks whether a named parameter is set.
+func (a *Arguments) HasParameter(name string) bool {
+	_, err := a.getNamedParameter(name)
+	return err == nil
+}
+
 func (a *Arguments) getNamedParameterValueAsUint(name string) (param uint, err error) {
 	var p *Parameter
 	p, err = a.getNamedParameter(name)
@@ -0,0 +1,197 @@
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	ae "github.com/vault-thirteen/auxie/errors"
+)
+
+const (
+	ErrFolderIsNotSet   = "cache folder is not set"
+	ErrfBadReference    = "bad reference to a cached page: %v"
+	ErrfPageIsNotCached = "page is not cached: forum %v, start %v"
+)
+
+const (
+	ObjectsFolder          = "objects"
+	PagesFolder            = "pages"
+	ObjectFileExt          = ".html"
+	ReferenceFileExt       = ".ref"
+	ReferenceLineSeparator = "\n"
+	ReferenceLinesCount    = 3
+	FolderPermissions      = 0755
+	FilePermissions        = 0644
+	TemporaryFilePattern   = "tmp-*"
+)
+
+// PageIsNotCachedError is returned when a page is requested from the cache
+// which does not have it.
+type PageIsNotCachedError struct {
+	ForumId      uint
+	StartItemIdx uint
+}
+
+func (e *PageIsNotCachedError) Error() string {
+	return fmt.Sprintf(ErrfPageIsNotCached, e.ForumId, e.StartItemIdx)
+}
+
+// PageInfo is a reference to a cached page. ETag and LastModified are
+// validators of the page sent by the server, they may be empty.
+type PageInfo struct {
+	Hash         string
+	ETag         string
+	LastModified string
+}
+
+// PageCache is a content-addressed cache of forum pages on disk. Contents of
+// pages are stored as objects named by their SHA-256 hashes, so that equal
+// pages are stored once. A page of a forum refers to its object by a small
+// reference file named by the ID of the forum and the start offset of the
+// page. The reference file has the hash on its first line, and the 'ETag' and
+// 'Last-Modified' validators of the page on the next lines:
+//
+//	objects/<2 first hash symbols>/<hash>.html
+//	pages/<forum ID>/<start offset>.ref
+//
+// Files are replaced atomically, so that an interrupted crawler does not
+// leave broken pages.
+type PageCache struct {
+	folder string
+}
+
+func NewPageCache(folder string) (pc *PageCache, err error) {
+	if len(folder) == 0 {
+		return nil, errors.New(ErrFolderIsNotSet)
+	}
+
+	err = os.MkdirAll(folder, FolderPermissions)
+	if err != nil {
+		return nil, err
+	}
+
+	return &PageCache{folder: folder}, nil
+}
+
+// Get reads a page of a forum. A page which is not cached is reported as a
+// 'PageIsNotCachedError'.
+func (pc *PageCache) Get(forumId uint, startItemIdx uint) (pageContents []byte, err error) {
+	var info *PageInfo
+	info, err = pc.GetInfo(forumId, startItemIdx)
+	if err != nil {
+		return nil, err
+	}
+
+	return os.ReadFile(pc.objectPath(info.Hash))
+}
+
+// GetInfo reads the reference to a page of a forum. A page which is not
+// cached is reported as a 'PageIsNotCachedError'.
+func (pc *PageCache) GetInfo(forumId uint, startItemIdx uint) (info *PageInfo, err error) {
+	refPath := pc.referencePath(forumId, startItemIdx)
+
+	var ref []byte
+	ref, err = os.ReadFile(refPath)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, &PageIsNotCachedError{ForumId: forumId, StartItemIdx: startItemIdx}
+		}
+		return nil, err
+	}
+
+	lines := strings.Split(string(ref), ReferenceLineSeparator)
+	for len(lines) < ReferenceLinesCount {
+		lines = append(lines, "")
+	}
+
+	info = &PageInfo{
+		Hash:         strings.TrimSpace(lines[0]),
+		ETag:         strings.TrimSpace(lines[1]),
+		LastModified: strings.TrimSpace(lines[2]),
+	}
+	if !isHash(info.Hash) {
+		return nil, fmt.Errorf(ErrfBadReference, refPath)
+	}
+
+	return info, nil
+}
+
+// Put saves a page of a forum replacing the previous version of the page.
+// Validators may be empty.
+func (pc *PageCache) Put(forumId uint, startItemIdx uint, pageContents []byte, eTag string, lastModified string) (err error) {
+	sum := sha256.Sum256(pageContents)
+	hash := hex.EncodeToString(sum[:])
+
+	objectPath := pc.objectPath(hash)
+	_, err = os.Stat(objectPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		err = writeFileAtomically(objectPath, pageContents)
+	}
+	if err != nil {
+		return err
+	}
+
+	ref := strings.Join([]string{hash, eTag, lastModified}, ReferenceLineSeparator)
+	return writeFileAtomically(pc.referencePath(forumId, startItemIdx), []byte(ref))
+}
+
+func (pc *PageCache) objectPath(hash string) string {
+	return filepath.Join(pc.folder, ObjectsFolder, hash[:2], hash+ObjectFileExt)
+}
+
+func (pc *PageCache) referencePath(forumId uint, startItemIdx uint) string {
+	return filepath.Join(pc.folder, PagesFolder, strconv.FormatUint(uint64(forumId), 10),
+		strconv.FormatUint(uint64(startItemIdx), 10)+ReferenceFileExt)
+}
+
+// writeFileAtomically writes a temporary file and renames it.
+func writeFileAtomically(path string, data []byte) (err error) {
+	folder := filepath.Dir(path)
+	err = os.MkdirAll(folder, FolderPermissions)
+	if err != nil {
+		return err
+	}
+
+	var f *os.File
+	f, err = os.CreateTemp(folder, TemporaryFilePattern)
+	if err != nil {
+		return err
+	}
+
+	_, err = f.Write(data)
+	if err == nil {
+		err = f.Chmod(FilePermissions)
+	}
+	cerr := f.Close()
+	if cerr != nil {
+		err = ae.Combine(err, cerr)
+	}
+	if err != nil {
+		_ = os.Remove(f.Name())
+		return err
+	}
+
+	err = os.Rename(f.Name(), path)
+	if err != nil {
+		_ = os.Remove(f.Name())
+		return err
+	}
+
+	return nil
+}
+
+func isHash(s string) bool {
+	if len(s) != sha256.Size*2 {
+		return false
+	}
+
+	_, err := hex.DecodeString(s)
+	return err == nil
+}
@@ -5,8 +5,10 @@ import (
 	"fmt"
 	"net"
 	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/go-sql-driver/mysql"
 	"github.com/vault-thirteen/Forum-Crawler/src/models"
@@ -16,12 +18,21 @@ import (
 type DB struct {
 	conn               *sql.DB
 	preparedStatements []*sql.Stmt
-	tempFolder         string
+	migrator           *Migrator
+
+	// Bulk queries are saved into the temporary folder for debugging when
+	// 'saveBulkQueries' is set.
+	tempFolder      string
+	saveBulkQueries bool
+
+	// Checkpoints of crawls.
+	*crawlStateStore
 }
 
 func NewDB(settings *models.DatabaseSettings) (db *DB, err error) {
 	db = &DB{
-		tempFolder: settings.TemporaryFolder,
+		tempFolder:      settings.TemporaryFolder,
+		saveBulkQueries: settings.SaveBulkQueries,
 	}
 
 	mc := mysql.Config{
@@ -32,6 +43,8 @@ func NewDB(settings *models.DatabaseSettings) (db *DB, err error) {
 		Passwd:               settings.Password,
 		AllowNativePasswords: true,
 		CheckConnLiveness:    true,
+		Collation:            Collation,
+		ParseTime:            true,
 		Params:               map[string]string{},
 	}
 	dsn := mc.FormatDSN()
@@ -45,7 +58,32 @@ func NewDB(settings *models.DatabaseSettings) (db *DB, err error) {
 		return nil, err
 	}
 
-	err = db.InitTables()
+	db.migrator, err = NewMigrator(db.conn, models.DatabaseDriver_MySQL, MigrationQueries{
+		CreateSchemaVersionTable: QueryCreateSchemaVersionTable,
+		SelectSchemaVersions:     QuerySelectSchemaVersions,
+		InsertSchemaVersion:      QueryInsertSchemaVersion,
+	}, nil, false) // DDL statements of MySQL commit implicitly.
+	if err != nil {
+		return nil, err
+	}
+
+	db.crawlStateStore = &crawlStateStore{
+		conn: db.conn,
+		queries: CrawlStateQueries{
+			SelectUnfinishedRun: QuerySelectUnfinishedCrawlRun,
+			InsertRun:           QueryInsertCrawlRun,
+			UpdateRun:           QueryUpdateCrawlRun,
+			SelectStates:        QuerySelectCrawlStates,
+			UpsertState:         QueryUpsertCrawlState,
+			UpsertStateArgs:     mysqlUpsertCrawlStateArgs,
+		},
+	}
+
+	if settings.MigrationOnly {
+		return db, nil
+	}
+
+	_, err = db.MigrateUp()
 	if err != nil {
 		return nil, err
 	}
@@ -67,18 +105,14 @@ func (db *DB) Close() (err error) {
 	return db.conn.Close()
 }
 
-func (db *DB) InitTables() (err error) {
-	_, err = db.conn.Exec(QueryCreateForumsTable)
-	if err != nil {
-		return err
-	}
-
-	_, err = db.conn.Exec(QueryCreateTopicsTable)
-	if err != nil {
-		return err
-	}
+// MigrateUp applies pending migrations of the database schema.
+func (db *DB) MigrateUp() (appliedCount int, err error) {
+	return db.migrator.Up()
+}
 
-	return nil
+// MigrationStatus lists migrations of the database schema.
+func (db *DB) MigrationStatus() (statuses []*MigrationStatus, err error) {
+	return db.migrator.Status()
 }
 
 func (db *DB) PrepareStatements() (err error) {
@@ -113,6 +147,76 @@ func (db *DB) PrepareStatements() (err error) {
 		}
 		db.preparedStatements = append(db.preparedStatements, st)
 	}
+	{
+		st, err = db.conn.Prepare(QueryUpsertArchivedTopic) // 4.
+		if err != nil {
+			return err
+		}
+		db.preparedStatements = append(db.preparedStatements, st)
+	}
+	{
+		st, err = db.conn.Prepare(QuerySelectTopic) // 5.
+		if err != nil {
+			return err
+		}
+		db.preparedStatements = append(db.preparedStatements, st)
+	}
+	{
+		st, err = db.conn.Prepare(QuerySelectArchivedTopic) // 6.
+		if err != nil {
+			return err
+		}
+		db.preparedStatements = append(db.preparedStatements, st)
+	}
+	{
+		st, err = db.conn.Prepare(QueryInsertTopicHistory) // 7.
+		if err != nil {
+			return err
+		}
+		db.preparedStatements = append(db.preparedStatements, st)
+	}
+	{
+		st, err = db.conn.Prepare(QuerySelectTopicsRange) // 8.
+		if err != nil {
+			return err
+		}
+		db.preparedStatements = append(db.preparedStatements, st)
+	}
+	{
+		st, err = db.conn.Prepare(QueryMarkMissingTopics) // 9.
+		if err != nil {
+			return err
+		}
+		db.preparedStatements = append(db.preparedStatements, st)
+	}
+	{
+		st, err = db.conn.Prepare(QueryMarkMissingArchivedTopics) // 10.
+		if err != nil {
+			return err
+		}
+		db.preparedStatements = append(db.preparedStatements, st)
+	}
+	{
+		st, err = db.conn.Prepare(QueryDeleteTopic) // 11.
+		if err != nil {
+			return err
+		}
+		db.preparedStatements = append(db.preparedStatements, st)
+	}
+	{
+		st, err = db.conn.Prepare(QueryDeleteArchivedTopic) // 12.
+		if err != nil {
+			return err
+		}
+		db.preparedStatements = append(db.preparedStatements, st)
+	}
+	{
+		st, err = db.conn.Prepare(QuerySelectArchivedTopicsRange) // 13.
+		if err != nil {
+			return err
+		}
+		db.preparedStatements = append(db.preparedStatements, st)
+	}
 	return nil
 }
 
@@ -152,8 +256,8 @@ func (db *DB) SaveForum(forum *models.Forum) (err error) {
 		}
 	}()
 
-	_, err = st.Exec(forum.ID, forum.Name,
-		forum.ID, forum.Name)
+	_, err = st.Exec(forum.ID, forum.Name, forum.CategoryId, forum.CategoryName, forum.ParentId, forum.Order, forum.Depth,
+		forum.ID, forum.Name, forum.CategoryId, forum.CategoryName, forum.ParentId, forum.Order, forum.Depth)
 	if err != nil {
 		return err
 	}
@@ -166,44 +270,65 @@ func (db *DB) SaveForum(forum *models.Forum) (err error) {
 	return nil
 }
 
-func (db *DB) SaveTopic(topic *models.Topic) (err error) {
-	var tx *sql.Tx
-	tx, err = db.conn.Begin()
-	if err != nil {
+// SaveTopic saves a topic into the table of topics or archived topics.
+// Changes of an existing topic are recorded into the history.
+func (db *DB) SaveTopic(topic *models.Topic, isArchived bool) (err error) {
+	return saveTopicsInTx(db.conn, db.preparedStatements, topicsSave{isArchived: isArchived}, func(sts *topicStatements) (err error) {
+		_, err = updateTopics(sts, map[uint]*models.Topic{topic.Id: topic}, mysqlUpsertTopicArgs)
 		return err
-	}
-	defer func() {
-		if err != nil {
-			derr := tx.Rollback()
-			if derr != nil {
-				err = ae.Combine(err, derr)
-			}
-		}
-	}()
+	})
+}
 
-	st := tx.Stmt(db.preparedStatements[PreparedStatementIdx_QueryUpsertTopic])
-	defer func() {
-		derr := st.Close()
-		if derr != nil {
-			err = ae.Combine(err, derr)
-		}
-	}()
+// SaveNewTopic saves a new topic, an existing topic is only marked as seen.
+// A topic found in the other table is moved into the table of the topic.
+func (db *DB) SaveNewTopic(topic *models.Topic, isArchived bool) (err error) {
+	return saveTopicsInTx(db.conn, db.preparedStatements, topicsSave{isArchived: isArchived, canMove: true, onlyNew: true}, func(sts *topicStatements) (err error) {
+		return saveNewTopic(sts, topic, mysqlInsertNewTopicArgs)
+	})
+}
 
-	_, err = st.Exec(topic.Id, topic.Name, topic.ForumId,
-		topic.Id, topic.Name, topic.ForumId)
-	if err != nil {
+// UpdateTopics updates names and forums of existing topics and inserts new
+// topics. Changes of existing topics are recorded into the history. Topics
+// found in the other table are moved into the table of topics.
+func (db *DB) UpdateTopics(topics map[uint]*models.Topic, isArchived bool) (stats *models.TopicsUpdateStats, err error) {
+	err = saveTopicsInTx(db.conn, db.preparedStatements, topicsSave{isArchived: isArchived, canMove: true}, func(sts *topicStatements) (err error) {
+		stats, err = updateTopics(sts, topics, mysqlUpsertTopicArgs)
 		return err
-	}
-
-	err = tx.Commit()
+	})
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	return nil
+	return stats, nil
 }
 
-func (db *DB) SaveNewTopic(topic *models.Topic, isArchived bool) (err error) {
+// mysqlUpsertTopicArgs returns arguments of the upsert of a topic, where values
+// of the update part are repeated.
+func mysqlUpsertTopicArgs(topic *models.Topic) []any {
+	firstSeenAt, lastSeenAt := seenTimes(topic)
+	return []any{topic.Id, topic.Name, topic.ForumId, firstSeenAt, lastSeenAt,
+		topic.Id, topic.Name, topic.ForumId, lastSeenAt}
+}
+
+// mysqlInsertNewTopicArgs returns arguments of the insert of a new topic,
+// where the time when it was last seen is repeated.
+func mysqlInsertNewTopicArgs(topic *models.Topic) []any {
+	firstSeenAt, lastSeenAt := seenTimes(topic)
+	return []any{topic.Id, topic.Name, topic.ForumId, firstSeenAt, lastSeenAt,
+		lastSeenAt}
+}
+
+// SaveTopics saves topics into the table of topics or archived topics using
+// a single multi-row query. Existing topics are updated, so that an
+// interrupted 'init' may be run again. Changes of existing topics are
+// recorded into the history. Topics are not moved between tables.
+func (db *DB) SaveTopics(forumId uint, topics map[uint]*models.Topic, isArchived bool) (err error) {
+	var topicsList = make([]*models.Topic, 0, len(topics))
+	for _, topic := range topics {
+		topicsList = append(topicsList, topic)
+	}
+	sort.Slice(topicsList, func(i, j int) bool { return topicsList[i].Id < topicsList[j].Id })
+
 	var tx *sql.Tx
 	tx, err = db.conn.Begin()
 	if err != nil {
@@ -218,20 +343,22 @@ func (db *DB) SaveNewTopic(topic *models.Topic, isArchived bool) (err error) {
 		}
 	}()
 
-	var st *sql.Stmt
-	if isArchived {
-		st = tx.Stmt(db.preparedStatements[PreparedStatementIdx_QueryInsertNewArchivedTopic])
-	} else {
-		st = tx.Stmt(db.preparedStatements[PreparedStatementIdx_QueryInsertNewTopic])
-	}
-	defer func() {
-		derr := st.Close()
-		if derr != nil {
-			err = ae.Combine(err, derr)
+	query := buildBulkUpsertTopicsQuery(topicsList, isArchived)
+
+	if db.saveBulkQueries {
+		queryFilePath := filepath.Join(db.tempFolder, fmt.Sprintf("forum_%v_%v.sql", forumId, topicsList[0].Id))
+		err = saveQueryToFile(queryFilePath, query)
+		if err != nil {
+			return err
 		}
-	}()
+	}
 
-	_, err = st.Exec(topic.Id, topic.Name, topic.ForumId)
+	err = db.recordTopicsChanges(tx, topicsList, isArchived)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(query)
 	if err != nil {
 		return err
 	}
@@ -244,60 +371,84 @@ func (db *DB) SaveNewTopic(topic *models.Topic, isArchived bool) (err error) {
 	return nil
 }
 
-func (db *DB) SaveTopics(forumId uint, topics map[uint]*models.Topic) (err error) {
-	var topicsList = make([]*models.Topic, 0, len(topics))
-	for _, topic := range topics {
-		topicsList = append(topicsList, topic)
+// buildBulkUpsertTopicsQuery builds a multi-row upsert of topics into the
+// table of topics or archived topics.
+func buildBulkUpsertTopicsQuery(sortedTopics []*models.Topic, isArchived bool) (query string) {
+	table := TableTopics
+	if isArchived {
+		table = TableTopicsArchived
 	}
 
 	var queryBuilder strings.Builder
-	queryBuilder.WriteString(`INSERT INTO Topics (ID, Name, ForumId) VALUES `)
+	queryBuilder.WriteString(`INSERT INTO ` + table + ` (ID, Name, ForumId, FirstSeenAt, LastSeenAt) VALUES `)
+
+	var firstSeenAt, lastSeenAt time.Time
+	for i, topic := range sortedTopics {
+		if i > 0 {
+			queryBuilder.WriteString(",\r\n")
+		}
 
-	iMax := len(topicsList) - 2
-	for i := 0; i <= iMax; i++ {
+		firstSeenAt, lastSeenAt = seenTimes(topic)
 		queryBuilder.WriteString("(" +
-			strconv.FormatUint(uint64(topicsList[i].Id), 10) + "," + // ID.
-			`'` + escapeString(topicsList[i].Name) + `',` + // Name.
-			strconv.FormatUint(uint64(topicsList[i].ForumId), 10) + "),\r\n") // ForumId.
+			strconv.FormatUint(uint64(topic.Id), 10) + "," + // ID.
+			`'` + escapeString(topic.Name) + `',` + // Name.
+			strconv.FormatUint(uint64(topic.ForumId), 10) + "," + // ForumId.
+			`'` + firstSeenAt.Format(DateTimeFormat) + `',` + // FirstSeenAt.
+			`'` + lastSeenAt.Format(DateTimeFormat) + `')`) // LastSeenAt.
 	}
-	iMax++
 
-	queryBuilder.WriteString("(" +
-		strconv.FormatUint(uint64(topicsList[iMax].Id), 10) + "," + // ID.
-		`'` + escapeString(topicsList[iMax].Name) + `',` + // Name.
-		strconv.FormatUint(uint64(topicsList[iMax].ForumId), 10) + ");") // ForumId.
+	queryBuilder.WriteString("\r\n" + QueryBulkUpsertTopicsSuffix)
 
-	var tx *sql.Tx
-	tx, err = db.conn.Begin()
-	if err != nil {
-		return err
+	return queryBuilder.String()
+}
+
+func (db *DB) recordTopicsChanges(tx *sql.Tx, sortedTopics []*models.Topic, isArchived bool) (err error) {
+	rangeIdx := PreparedStatementIdx_QuerySelectTopicsRange
+	if isArchived {
+		rangeIdx = PreparedStatementIdx_QuerySelectArchivedTopicsRange
 	}
+
+	stSelectRange := tx.Stmt(db.preparedStatements[rangeIdx])
+	stInsertHistory := tx.Stmt(db.preparedStatements[PreparedStatementIdx_QueryInsertTopicHistory])
 	defer func() {
-		if err != nil {
-			derr := tx.Rollback()
-			if derr != nil {
-				err = ae.Combine(err, derr)
-			}
+		derr := stSelectRange.Close()
+		if derr != nil {
+			err = ae.Combine(err, derr)
+		}
+		derr = stInsertHistory.Close()
+		if derr != nil {
+			err = ae.Combine(err, derr)
 		}
 	}()
 
-	query := queryBuilder.String()
+	return recordTopicsChanges(stSelectRange, stInsertHistory, sortedTopics)
+}
 
-	queryFilePath := filepath.Join(db.tempFolder, fmt.Sprintf("forum_%v.sql", forumId))
-	err = saveQueryToFile(queryFilePath, query)
-	if err != nil {
-		return err
+// MarkMissingTopics marks topics of a forum which have not been seen since the
+// start of a full crawl of the forum.
+func (db *DB) MarkMissingTopics(forumId uint, crawlStartedAt time.Time, isArchived bool) (count int64, err error) {
+	var st *sql.Stmt
+	if isArchived {
+		st = db.preparedStatements[PreparedStatementIdx_QueryMarkMissingArchivedTopics]
+	} else {
+		st = db.preparedStatements[PreparedStatementIdx_QueryMarkMissingTopics]
 	}
 
-	_, err = tx.Exec(query)
+	var result sql.Result
+	result, err = st.Exec(time.Now().UTC().Truncate(time.Second), forumId, crawlStartedAt)
 	if err != nil {
-		return err
+		return 0, err
 	}
 
-	err = tx.Commit()
-	if err != nil {
-		return err
-	}
+	return result.RowsAffected()
+}
 
-	return nil
+// KeepsTopicState tells that the database keeps the state of topics.
+func (db *DB) KeepsTopicState() bool {
+	return true
+}
+
+// GetTopicHistory reads changes of a topic.
+func (db *DB) GetTopicHistory(topicId uint) (changes []*models.TopicChange, err error) {
+	return getTopicHistory(db.conn, QuerySelectTopicHistory, topicId)
 }
@@ -0,0 +1,331 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	ae "github.com/vault-thirteen/auxie/errors"
+)
+
+const (
+	ErrMigrationFileName   = "bad migration file name: %v"
+	ErrMigrationVersionDup = "duplicate migration version: %v"
+	ErrMigrationFailed     = "migration %v (%v) has failed: %v"
+)
+
+const (
+	MigrationsFolder             = "migrations"
+	MigrationFileExt             = ".sql"
+	MigrationFileNameSeparator   = "_"
+	MigrationCommentPrefix       = "--"
+	MigrationStatementTerminator = ";"
+)
+
+//go:embed migrations
+var migrationsFS embed.FS
+
+// Migration is a single step of the database schema evolution.
+type Migration struct {
+	Version    uint
+	Name       string
+	Statements []string
+}
+
+// MigrationStatus shows whether a migration has been applied.
+type MigrationStatus struct {
+	Version   uint
+	Name      string
+	IsApplied bool
+	AppliedAt time.Time
+}
+
+// MigrationQueries are dialect-specific queries of the 'SchemaVersion' table.
+type MigrationQueries struct {
+	CreateSchemaVersionTable string
+	SelectSchemaVersions     string
+	InsertSchemaVersion      string
+}
+
+// Migrator applies embedded migrations of a dialect in the order of their
+// versions. Applied versions are stored in the 'SchemaVersion' table.
+type Migrator struct {
+	conn       *sql.DB
+	queries    MigrationQueries
+	migrations []*Migration
+
+	// isTransactional is set for dialects which roll back DDL statements, so
+	// that a migration is applied either completely or not at all.
+	isTransactional bool
+}
+
+// NewMigrator loads migrations of the dialect. The replacer is used to put
+// settings into the text of migrations, it may be nil.
+func NewMigrator(conn *sql.DB, dialect string, queries MigrationQueries, replacer *strings.Replacer, isTransactional bool) (m *Migrator, err error) {
+	m = &Migrator{
+		conn:            conn,
+		queries:         queries,
+		isTransactional: isTransactional,
+	}
+
+	m.migrations, err = loadMigrations(path.Join(MigrationsFolder, dialect), replacer)
+	if err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+func loadMigrations(folder string, replacer *strings.Replacer) (migrations []*Migration, err error) {
+	var entries []fs.DirEntry
+	entries, err = migrationsFS.ReadDir(folder)
+	if err != nil {
+		return nil, err
+	}
+
+	migrations = make([]*Migration, 0, len(entries))
+	versions := make(map[uint]bool)
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), MigrationFileExt) {
+			continue
+		}
+
+		var migration *Migration
+		migration, err = parseMigrationFileName(entry.Name())
+		if err != nil {
+			return nil, err
+		}
+		if versions[migration.Version] {
+			return nil, fmt.Errorf(ErrMigrationVersionDup, migration.Version)
+		}
+		versions[migration.Version] = true
+
+		var buf []byte
+		buf, err = migrationsFS.ReadFile(path.Join(folder, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		text := string(buf)
+		if replacer != nil {
+			text = replacer.Replace(text)
+		}
+		migration.Statements = splitStatements(text)
+
+		migrations = append(migrations, migration)
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	return migrations, nil
+}
+
+// parseMigrationFileName parses names like '001_Create_Table_Forums.sql'.
+func parseMigrationFileName(fileName string) (migration *Migration, err error) {
+	name := strings.TrimSuffix(fileName, MigrationFileExt)
+	parts := strings.SplitN(name, MigrationFileNameSeparator, 2)
+	if len(parts) != 2 {
+		return nil, fmt.Errorf(ErrMigrationFileName, fileName)
+	}
+
+	var version uint64
+	version, err = strconv.ParseUint(parts[0], 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf(ErrMigrationFileName, fileName)
+	}
+
+	return &Migration{
+		Version: uint(version),
+		Name:    parts[1],
+	}, nil
+}
+
+// splitStatements splits text into statements. Each statement must end with a
+// semicolon at the end of a line. Comment lines are skipped.
+func splitStatements(text string) (statements []string) {
+	statements = make([]string, 0)
+	var sb strings.Builder
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimRight(line, "\r")
+		trimmedLine := strings.TrimSpace(line)
+		if len(trimmedLine) == 0 || strings.HasPrefix(trimmedLine, MigrationCommentPrefix) {
+			continue
+		}
+
+		if sb.Len() > 0 {
+			sb.WriteString("\n")
+		}
+		sb.WriteString(line)
+
+		if strings.HasSuffix(trimmedLine, MigrationStatementTerminator) {
+			statements = append(statements, sb.String())
+			sb.Reset()
+		}
+	}
+
+	if len(strings.TrimSpace(sb.String())) > 0 {
+		statements = append(statements, sb.String())
+	}
+
+	return statements
+}
+
+// Up applies all the migrations which have not been applied yet.
+func (m *Migrator) Up() (appliedCount int, err error) {
+	var appliedVersions map[uint]time.Time
+	appliedVersions, err = m.getAppliedVersions()
+	if err != nil {
+		return 0, err
+	}
+
+	for _, migration := range m.migrations {
+		_, isApplied := appliedVersions[migration.Version]
+		if isApplied {
+			continue
+		}
+
+		err = m.apply(migration)
+		if err != nil {
+			return appliedCount, fmt.Errorf(ErrMigrationFailed, migration.Version, migration.Name, err)
+		}
+		appliedCount++
+	}
+
+	return appliedCount, nil
+}
+
+// Status lists all the known migrations.
+func (m *Migrator) Status() (statuses []*MigrationStatus, err error) {
+	var appliedVersions map[uint]time.Time
+	appliedVersions, err = m.getAppliedVersions()
+	if err != nil {
+		return nil, err
+	}
+
+	statuses = make([]*MigrationStatus, 0, len(m.migrations))
+	for _, migration := range m.migrations {
+		appliedAt, isApplied := appliedVersions[migration.Version]
+		statuses = append(statuses, &MigrationStatus{
+			Version:   migration.Version,
+			Name:      migration.Name,
+			IsApplied: isApplied,
+			AppliedAt: appliedAt,
+		})
+	}
+
+	return statuses, nil
+}
+
+func (m *Migrator) getAppliedVersions() (versions map[uint]time.Time, err error) {
+	_, err = m.conn.Exec(m.queries.CreateSchemaVersionTable)
+	if err != nil {
+		return nil, err
+	}
+
+	var rows *sql.Rows
+	rows, err = m.conn.Query(m.queries.SelectSchemaVersions)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		derr := rows.Close()
+		if derr != nil {
+			err = ae.Combine(err, derr)
+		}
+	}()
+
+	versions = make(map[uint]time.Time)
+	var version uint
+	var appliedAt time.Time
+	for rows.Next() {
+		err = rows.Scan(&version, &appliedAt)
+		if err != nil {
+			return nil, err
+		}
+		versions[version] = appliedAt
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+
+	return versions, nil
+}
+
+// apply executes statements of a migration using a single connection, so that
+// session variables are kept between statements. In transactional dialects the
+// migration and its version are saved in a single transaction. Elsewhere DDL
+// statements commit implicitly, so migrations must be safe to re-run after a
+// partial failure.
+func (m *Migrator) apply(migration *Migration) (err error) {
+	ctx := context.Background()
+
+	var conn *sql.Conn
+	conn, err = m.conn.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		derr := conn.Close()
+		if derr != nil {
+			err = ae.Combine(err, derr)
+		}
+	}()
+
+	if !m.isTransactional {
+		return m.applyStatements(ctx, conn, migration)
+	}
+
+	var tx *sql.Tx
+	tx, err = conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			derr := tx.Rollback()
+			if derr != nil {
+				err = ae.Combine(err, derr)
+			}
+		}
+	}()
+
+	err = m.applyStatements(ctx, tx, migration)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// execer is a connection or a transaction.
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// applyStatements executes statements of a migration and saves its version.
+func (m *Migrator) applyStatements(ctx context.Context, ex execer, migration *Migration) (err error) {
+	for _, statement := range migration.Statements {
+		_, err = ex.ExecContext(ctx, statement)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = ex.ExecContext(ctx, m.queries.InsertSchemaVersion, migration.Version, migration.Name, time.Now().UTC())
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,51 @@
+package db
+
+import (
+	"testing"
+)
+
+func Test_Migrator_Up_Transactional(t *testing.T) {
+	db := mustOpenSQLiteDB(t)
+	m := &Migrator{
+		conn:    db.conn,
+		queries: db.migrator.queries,
+		migrations: []*Migration{{
+			Version: 1000,
+			Name:    "Failing",
+			Statements: []string{
+				`ALTER TABLE Topics ADD COLUMN Extra INTEGER NULL;`,
+				`ALTER TABLE NoSuchTable ADD COLUMN Extra INTEGER NULL;`,
+			},
+		}},
+		isTransactional: true,
+	}
+
+	// Test #1. A failed migration is rolled back completely.
+	_, err := m.Up()
+	if err == nil {
+		t.Fatal("error is expected")
+	}
+
+	var count int
+	err = db.conn.QueryRow(`SELECT COUNT(*) FROM pragma_table_info('Topics') WHERE name = 'Extra';`).Scan(&count)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustBeEqual(t, count, 0)
+
+	var statuses []*MigrationStatus
+	statuses, err = m.Status()
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustBeEqual(t, statuses[0].IsApplied, false)
+
+	// Test #2. The fixed migration is applied again.
+	m.migrations[0].Statements = m.migrations[0].Statements[:1]
+	var appliedCount int
+	appliedCount, err = m.Up()
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustBeEqual(t, appliedCount, 1)
+}
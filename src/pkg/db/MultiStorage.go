@@ -0,0 +1,133 @@
+package db
+
+import (
+	"time"
+
+	"github.com/vault-thirteen/Forum-Crawler/src/models"
+	ae "github.com/vault-thirteen/auxie/errors"
+)
+
+// MultiStorage passes all the data to several storages in turn.
+type MultiStorage struct {
+	storages []Storage
+}
+
+func NewMultiStorage(storages ...Storage) (ms *MultiStorage) {
+	return &MultiStorage{
+		storages: storages,
+	}
+}
+
+// Close closes all the storages, even if some of them fail.
+func (ms *MultiStorage) Close() (err error) {
+	for _, s := range ms.storages {
+		cerr := s.Close()
+		if cerr != nil {
+			err = ae.Combine(err, cerr)
+		}
+	}
+
+	return err
+}
+
+func (ms *MultiStorage) SaveForum(forum *models.Forum) (err error) {
+	for _, s := range ms.storages {
+		err = s.SaveForum(forum)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (ms *MultiStorage) SaveTopic(topic *models.Topic, isArchived bool) (err error) {
+	for _, s := range ms.storages {
+		err = s.SaveTopic(topic, isArchived)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (ms *MultiStorage) SaveNewTopic(topic *models.Topic, isArchived bool) (err error) {
+	for _, s := range ms.storages {
+		err = s.SaveNewTopic(topic, isArchived)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (ms *MultiStorage) SaveTopics(forumId uint, topics map[uint]*models.Topic, isArchived bool) (err error) {
+	for _, s := range ms.storages {
+		err = s.SaveTopics(forumId, topics, isArchived)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// UpdateTopics returns statistics of the first storage which keeps the state
+// of topics, i.e. of a database. Storages without the state, e.g. files, can
+// not tell added topics from updated ones, so that zero counts are returned
+// when there is no such storage.
+func (ms *MultiStorage) UpdateTopics(topics map[uint]*models.Topic, isArchived bool) (stats *models.TopicsUpdateStats, err error) {
+	var st *models.TopicsUpdateStats
+	for _, s := range ms.storages {
+		st, err = s.UpdateTopics(topics, isArchived)
+		if err != nil {
+			return nil, err
+		}
+		if (stats == nil) && keepsState(s) {
+			stats = st
+		}
+	}
+
+	if stats == nil {
+		stats = &models.TopicsUpdateStats{}
+	}
+
+	return stats, nil
+}
+
+// MarkMissingTopics returns the count of the first storage which keeps the
+// state of topics.
+func (ms *MultiStorage) MarkMissingTopics(forumId uint, crawlStartedAt time.Time, isArchived bool) (count int64, err error) {
+	var c int64
+	isCounted := false
+	for _, s := range ms.storages {
+		c, err = s.MarkMissingTopics(forumId, crawlStartedAt, isArchived)
+		if err != nil {
+			return 0, err
+		}
+		if !isCounted && keepsState(s) {
+			count, isCounted = c, true
+		}
+	}
+
+	return count, nil
+}
+
+// KeepsTopicState tells whether any of the storages keeps the state of topics.
+func (ms *MultiStorage) KeepsTopicState() bool {
+	for _, s := range ms.storages {
+		if keepsState(s) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// keepsState checks whether a storage keeps the state of topics.
+func keepsState(s Storage) bool {
+	tsk, ok := s.(TopicStateKeeper)
+	return ok && tsk.KeepsTopicState()
+}
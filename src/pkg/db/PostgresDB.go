@@ -0,0 +1,337 @@
+package db
+
+import (
+	"database/sql"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/vault-thirteen/Forum-Crawler/src/models"
+	ae "github.com/vault-thirteen/auxie/errors"
+)
+
+const (
+	PostgresDriverName = "postgres"
+)
+
+// PostgresDB is a PostgreSQL database.
+type PostgresDB struct {
+	conn               *sql.DB
+	preparedStatements []*sql.Stmt
+	migrator           *Migrator
+	textSearchConfig   string
+
+	// Checkpoints of crawls.
+	*crawlStateStore
+}
+
+func NewPostgresDB(settings *models.DatabaseSettings) (db *PostgresDB, err error) {
+	db = &PostgresDB{
+		textSearchConfig: settings.TextSearchConfig,
+	}
+	if len(db.textSearchConfig) == 0 {
+		db.textSearchConfig = PostgresDefaultTextSearchConfig
+	}
+
+	dsn := url.URL{
+		Scheme: PostgresDriverName,
+		User:   url.UserPassword(settings.User, settings.Password),
+		Host:   net.JoinHostPort(settings.Host, strconv.FormatUint(uint64(settings.Port), 10)),
+		Path:   settings.Db,
+	}
+	if len(settings.SslMode) > 0 {
+		dsn.RawQuery = url.Values{"sslmode": []string{settings.SslMode}}.Encode()
+	}
+
+	db.conn, err = sql.Open(PostgresDriverName, dsn.String())
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.conn.Ping()
+	if err != nil {
+		return nil, err
+	}
+
+	db.migrator, err = NewMigrator(db.conn, models.DatabaseDriver_Postgres, MigrationQueries{
+		CreateSchemaVersionTable: QueryPostgresCreateSchemaVersionTable,
+		SelectSchemaVersions:     QueryPostgresSelectSchemaVersions,
+		InsertSchemaVersion:      QueryPostgresInsertSchemaVersion,
+	}, strings.NewReplacer(PostgresTextSearchConfigPlaceholder, pq.QuoteLiteral(db.textSearchConfig)), true)
+	if err != nil {
+		return nil, err
+	}
+
+	db.crawlStateStore = &crawlStateStore{
+		conn: db.conn,
+		queries: CrawlStateQueries{
+			SelectUnfinishedRun: QueryPostgresSelectUnfinishedCrawlRun,
+			InsertRun:           QueryPostgresInsertCrawlRun,
+			UpdateRun:           QueryPostgresUpdateCrawlRun,
+			SelectStates:        QueryPostgresSelectCrawlStates,
+			UpsertState:         QueryPostgresUpsertCrawlState,
+			UpsertStateArgs:     upsertCrawlStateArgs,
+		},
+	}
+
+	if settings.MigrationOnly {
+		return db, nil
+	}
+
+	_, err = db.MigrateUp()
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.PrepareStatements()
+	if err != nil {
+		return nil, err
+	}
+
+	return db, nil
+}
+
+func (db *PostgresDB) Close() (err error) {
+	err = db.CloseStatements()
+	if err != nil {
+		return err
+	}
+
+	return db.conn.Close()
+}
+
+// MigrateUp applies pending migrations of the database schema.
+func (db *PostgresDB) MigrateUp() (appliedCount int, err error) {
+	return db.migrator.Up()
+}
+
+// MigrationStatus lists migrations of the database schema.
+func (db *PostgresDB) MigrationStatus() (statuses []*MigrationStatus, err error) {
+	return db.migrator.Status()
+}
+
+func (db *PostgresDB) PrepareStatements() (err error) {
+	db.preparedStatements = make([]*sql.Stmt, 0)
+
+	var st *sql.Stmt
+	{
+		st, err = db.conn.Prepare(QueryPostgresUpsertForum) // 0.
+		if err != nil {
+			return err
+		}
+		db.preparedStatements = append(db.preparedStatements, st)
+	}
+	{
+		st, err = db.conn.Prepare(QueryPostgresUpsertTopic) // 1.
+		if err != nil {
+			return err
+		}
+		db.preparedStatements = append(db.preparedStatements, st)
+	}
+	{
+		st, err = db.conn.Prepare(QueryPostgresInsertNewTopic) // 2.
+		if err != nil {
+			return err
+		}
+		db.preparedStatements = append(db.preparedStatements, st)
+	}
+	{
+		st, err = db.conn.Prepare(QueryPostgresInsertNewArchivedTopic) // 3.
+		if err != nil {
+			return err
+		}
+		db.preparedStatements = append(db.preparedStatements, st)
+	}
+	{
+		st, err = db.conn.Prepare(QueryPostgresUpsertArchivedTopic) // 4.
+		if err != nil {
+			return err
+		}
+		db.preparedStatements = append(db.preparedStatements, st)
+	}
+	{
+		st, err = db.conn.Prepare(QueryPostgresSelectTopic) // 5.
+		if err != nil {
+			return err
+		}
+		db.preparedStatements = append(db.preparedStatements, st)
+	}
+	{
+		st, err = db.conn.Prepare(QueryPostgresSelectArchivedTopic) // 6.
+		if err != nil {
+			return err
+		}
+		db.preparedStatements = append(db.preparedStatements, st)
+	}
+	{
+		st, err = db.conn.Prepare(QueryPostgresInsertTopicHistory) // 7.
+		if err != nil {
+			return err
+		}
+		db.preparedStatements = append(db.preparedStatements, st)
+	}
+	{
+		st, err = db.conn.Prepare(QueryPostgresSelectTopicsRange) // 8.
+		if err != nil {
+			return err
+		}
+		db.preparedStatements = append(db.preparedStatements, st)
+	}
+	{
+		st, err = db.conn.Prepare(QueryPostgresMarkMissingTopics) // 9.
+		if err != nil {
+			return err
+		}
+		db.preparedStatements = append(db.preparedStatements, st)
+	}
+	{
+		st, err = db.conn.Prepare(QueryPostgresMarkMissingArchivedTopics) // 10.
+		if err != nil {
+			return err
+		}
+		db.preparedStatements = append(db.preparedStatements, st)
+	}
+	{
+		st, err = db.conn.Prepare(QueryPostgresDeleteTopic) // 11.
+		if err != nil {
+			return err
+		}
+		db.preparedStatements = append(db.preparedStatements, st)
+	}
+	{
+		st, err = db.conn.Prepare(QueryPostgresDeleteArchivedTopic) // 12.
+		if err != nil {
+			return err
+		}
+		db.preparedStatements = append(db.preparedStatements, st)
+	}
+	{
+		st, err = db.conn.Prepare(QueryPostgresSelectArchivedTopicsRange) // 13.
+		if err != nil {
+			return err
+		}
+		db.preparedStatements = append(db.preparedStatements, st)
+	}
+	return nil
+}
+
+func (db *PostgresDB) CloseStatements() (err error) {
+	for _, st := range db.preparedStatements {
+		err = st.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	db.preparedStatements = nil
+
+	return nil
+}
+
+func (db *PostgresDB) SaveForum(forum *models.Forum) (err error) {
+	var tx *sql.Tx
+	tx, err = db.conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			derr := tx.Rollback()
+			if derr != nil {
+				err = ae.Combine(err, derr)
+			}
+		}
+	}()
+
+	st := tx.Stmt(db.preparedStatements[PreparedStatementIdx_QueryUpsertForum])
+	defer func() {
+		derr := st.Close()
+		if derr != nil {
+			err = ae.Combine(err, derr)
+		}
+	}()
+
+	_, err = st.Exec(forum.ID, forum.Name, forum.CategoryId, forum.CategoryName, forum.ParentId, forum.Order, forum.Depth)
+	if err != nil {
+		return err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// SaveTopic saves a topic into the table of topics or archived topics.
+// Changes of an existing topic are recorded into the history.
+func (db *PostgresDB) SaveTopic(topic *models.Topic, isArchived bool) (err error) {
+	return db.SaveTopics(topic.ForumId, map[uint]*models.Topic{topic.Id: topic}, isArchived)
+}
+
+// SaveNewTopic saves a new topic, an existing topic is only marked as seen.
+// A topic found in the other table is moved into the table of the topic.
+func (db *PostgresDB) SaveNewTopic(topic *models.Topic, isArchived bool) (err error) {
+	return saveTopicsInTx(db.conn, db.preparedStatements, topicsSave{isArchived: isArchived, canMove: true, onlyNew: true}, func(sts *topicStatements) (err error) {
+		return saveNewTopic(sts, topic, upsertTopicArgs)
+	})
+}
+
+// UpdateTopics updates names and forums of existing topics and inserts new
+// topics. Changes of existing topics are recorded into the history. Topics
+// found in the other table are moved into the table of topics.
+func (db *PostgresDB) UpdateTopics(topics map[uint]*models.Topic, isArchived bool) (stats *models.TopicsUpdateStats, err error) {
+	err = saveTopicsInTx(db.conn, db.preparedStatements, topicsSave{isArchived: isArchived, canMove: true}, func(sts *topicStatements) (err error) {
+		stats, err = updateTopics(sts, topics, upsertTopicArgs)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return stats, nil
+}
+
+// SaveTopics saves topics in a single transaction. Existing topics are
+// updated, so that an interrupted 'init' may be run again. Changes of
+// existing topics are recorded into the history. Topics are not moved
+// between tables.
+func (db *PostgresDB) SaveTopics(forumId uint, topics map[uint]*models.Topic, isArchived bool) (err error) {
+	return saveTopicsInTx(db.conn, db.preparedStatements, topicsSave{isArchived: isArchived}, func(sts *topicStatements) (err error) {
+		_, err = updateTopics(sts, topics, upsertTopicArgs)
+		return err
+	})
+}
+
+// MarkMissingTopics marks topics of a forum which have not been seen since the
+// start of a full crawl of the forum.
+func (db *PostgresDB) MarkMissingTopics(forumId uint, crawlStartedAt time.Time, isArchived bool) (count int64, err error) {
+	var st *sql.Stmt
+	if isArchived {
+		st = db.preparedStatements[PreparedStatementIdx_QueryMarkMissingArchivedTopics]
+	} else {
+		st = db.preparedStatements[PreparedStatementIdx_QueryMarkMissingTopics]
+	}
+
+	var result sql.Result
+	result, err = st.Exec(time.Now().UTC().Truncate(time.Second), forumId, crawlStartedAt)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
+// KeepsTopicState tells that the database keeps the state of topics.
+func (db *PostgresDB) KeepsTopicState() bool {
+	return true
+}
+
+// GetTopicHistory reads changes of a topic.
+func (db *PostgresDB) GetTopicHistory(topicId uint) (changes []*models.TopicChange, err error) {
+	return getTopicHistory(db.conn, QueryPostgresSelectTopicHistory, topicId)
+}
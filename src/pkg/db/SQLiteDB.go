@@ -0,0 +1,323 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/vault-thirteen/Forum-Crawler/src/models"
+	ae "github.com/vault-thirteen/auxie/errors"
+	_ "modernc.org/sqlite"
+)
+
+const (
+	SQLiteDriverName = "sqlite"
+)
+
+// SQLiteDB is an embedded single-file database.
+type SQLiteDB struct {
+	conn               *sql.DB
+	preparedStatements []*sql.Stmt
+	migrator           *Migrator
+
+	// Checkpoints of crawls.
+	*crawlStateStore
+}
+
+func NewSQLiteDB(settings *models.DatabaseSettings) (db *SQLiteDB, err error) {
+	db = &SQLiteDB{}
+
+	// Times are written in a sortable format, so that they can be compared.
+	dsn := fmt.Sprintf("file:%v?_pragma=busy_timeout(%v)&_time_format=sqlite", settings.File, SQLiteBusyTimeoutMs)
+	db.conn, err = sql.Open(SQLiteDriverName, dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	// SQLite allows only a single writer at a time.
+	db.conn.SetMaxOpenConns(1)
+
+	err = db.conn.Ping()
+	if err != nil {
+		return nil, err
+	}
+
+	db.migrator, err = NewMigrator(db.conn, models.DatabaseDriver_SQLite, MigrationQueries{
+		CreateSchemaVersionTable: QuerySQLiteCreateSchemaVersionTable,
+		SelectSchemaVersions:     QuerySQLiteSelectSchemaVersions,
+		InsertSchemaVersion:      QuerySQLiteInsertSchemaVersion,
+	}, nil, true)
+	if err != nil {
+		return nil, err
+	}
+
+	db.crawlStateStore = &crawlStateStore{
+		conn: db.conn,
+		queries: CrawlStateQueries{
+			SelectUnfinishedRun: QuerySQLiteSelectUnfinishedCrawlRun,
+			InsertRun:           QuerySQLiteInsertCrawlRun,
+			UpdateRun:           QuerySQLiteUpdateCrawlRun,
+			SelectStates:        QuerySQLiteSelectCrawlStates,
+			UpsertState:         QuerySQLiteUpsertCrawlState,
+			UpsertStateArgs:     upsertCrawlStateArgs,
+		},
+	}
+
+	if settings.MigrationOnly {
+		return db, nil
+	}
+
+	_, err = db.MigrateUp()
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.PrepareStatements()
+	if err != nil {
+		return nil, err
+	}
+
+	return db, nil
+}
+
+func (db *SQLiteDB) Close() (err error) {
+	err = db.CloseStatements()
+	if err != nil {
+		return err
+	}
+
+	return db.conn.Close()
+}
+
+// MigrateUp applies pending migrations of the database schema.
+func (db *SQLiteDB) MigrateUp() (appliedCount int, err error) {
+	return db.migrator.Up()
+}
+
+// MigrationStatus lists migrations of the database schema.
+func (db *SQLiteDB) MigrationStatus() (statuses []*MigrationStatus, err error) {
+	return db.migrator.Status()
+}
+
+func (db *SQLiteDB) PrepareStatements() (err error) {
+	db.preparedStatements = make([]*sql.Stmt, 0)
+
+	var st *sql.Stmt
+	{
+		st, err = db.conn.Prepare(QuerySQLiteUpsertForum) // 0.
+		if err != nil {
+			return err
+		}
+		db.preparedStatements = append(db.preparedStatements, st)
+	}
+	{
+		st, err = db.conn.Prepare(QuerySQLiteUpsertTopic) // 1.
+		if err != nil {
+			return err
+		}
+		db.preparedStatements = append(db.preparedStatements, st)
+	}
+	{
+		st, err = db.conn.Prepare(QuerySQLiteInsertNewTopic) // 2.
+		if err != nil {
+			return err
+		}
+		db.preparedStatements = append(db.preparedStatements, st)
+	}
+	{
+		st, err = db.conn.Prepare(QuerySQLiteInsertNewArchivedTopic) // 3.
+		if err != nil {
+			return err
+		}
+		db.preparedStatements = append(db.preparedStatements, st)
+	}
+	{
+		st, err = db.conn.Prepare(QuerySQLiteUpsertArchivedTopic) // 4.
+		if err != nil {
+			return err
+		}
+		db.preparedStatements = append(db.preparedStatements, st)
+	}
+	{
+		st, err = db.conn.Prepare(QuerySQLiteSelectTopic) // 5.
+		if err != nil {
+			return err
+		}
+		db.preparedStatements = append(db.preparedStatements, st)
+	}
+	{
+		st, err = db.conn.Prepare(QuerySQLiteSelectArchivedTopic) // 6.
+		if err != nil {
+			return err
+		}
+		db.preparedStatements = append(db.preparedStatements, st)
+	}
+	{
+		st, err = db.conn.Prepare(QuerySQLiteInsertTopicHistory) // 7.
+		if err != nil {
+			return err
+		}
+		db.preparedStatements = append(db.preparedStatements, st)
+	}
+	{
+		st, err = db.conn.Prepare(QuerySQLiteSelectTopicsRange) // 8.
+		if err != nil {
+			return err
+		}
+		db.preparedStatements = append(db.preparedStatements, st)
+	}
+	{
+		st, err = db.conn.Prepare(QuerySQLiteMarkMissingTopics) // 9.
+		if err != nil {
+			return err
+		}
+		db.preparedStatements = append(db.preparedStatements, st)
+	}
+	{
+		st, err = db.conn.Prepare(QuerySQLiteMarkMissingArchivedTopics) // 10.
+		if err != nil {
+			return err
+		}
+		db.preparedStatements = append(db.preparedStatements, st)
+	}
+	{
+		st, err = db.conn.Prepare(QuerySQLiteDeleteTopic) // 11.
+		if err != nil {
+			return err
+		}
+		db.preparedStatements = append(db.preparedStatements, st)
+	}
+	{
+		st, err = db.conn.Prepare(QuerySQLiteDeleteArchivedTopic) // 12.
+		if err != nil {
+			return err
+		}
+		db.preparedStatements = append(db.preparedStatements, st)
+	}
+	{
+		st, err = db.conn.Prepare(QuerySQLiteSelectArchivedTopicsRange) // 13.
+		if err != nil {
+			return err
+		}
+		db.preparedStatements = append(db.preparedStatements, st)
+	}
+	return nil
+}
+
+func (db *SQLiteDB) CloseStatements() (err error) {
+	for _, st := range db.preparedStatements {
+		err = st.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	db.preparedStatements = nil
+
+	return nil
+}
+
+func (db *SQLiteDB) SaveForum(forum *models.Forum) (err error) {
+	var tx *sql.Tx
+	tx, err = db.conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			derr := tx.Rollback()
+			if derr != nil {
+				err = ae.Combine(err, derr)
+			}
+		}
+	}()
+
+	st := tx.Stmt(db.preparedStatements[PreparedStatementIdx_QueryUpsertForum])
+	defer func() {
+		derr := st.Close()
+		if derr != nil {
+			err = ae.Combine(err, derr)
+		}
+	}()
+
+	_, err = st.Exec(forum.ID, forum.Name, forum.CategoryId, forum.CategoryName, forum.ParentId, forum.Order, forum.Depth)
+	if err != nil {
+		return err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// SaveTopic saves a topic into the table of topics or archived topics.
+// Changes of an existing topic are recorded into the history.
+func (db *SQLiteDB) SaveTopic(topic *models.Topic, isArchived bool) (err error) {
+	return db.SaveTopics(topic.ForumId, map[uint]*models.Topic{topic.Id: topic}, isArchived)
+}
+
+// SaveNewTopic saves a new topic, an existing topic is only marked as seen.
+// A topic found in the other table is moved into the table of the topic.
+func (db *SQLiteDB) SaveNewTopic(topic *models.Topic, isArchived bool) (err error) {
+	return saveTopicsInTx(db.conn, db.preparedStatements, topicsSave{isArchived: isArchived, canMove: true, onlyNew: true}, func(sts *topicStatements) (err error) {
+		return saveNewTopic(sts, topic, upsertTopicArgs)
+	})
+}
+
+// UpdateTopics updates names and forums of existing topics and inserts new
+// topics. Changes of existing topics are recorded into the history. Topics
+// found in the other table are moved into the table of topics.
+func (db *SQLiteDB) UpdateTopics(topics map[uint]*models.Topic, isArchived bool) (stats *models.TopicsUpdateStats, err error) {
+	err = saveTopicsInTx(db.conn, db.preparedStatements, topicsSave{isArchived: isArchived, canMove: true}, func(sts *topicStatements) (err error) {
+		stats, err = updateTopics(sts, topics, upsertTopicArgs)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return stats, nil
+}
+
+// SaveTopics saves topics in a single transaction. Existing topics are
+// updated, so that an interrupted 'init' may be run again. Changes of
+// existing topics are recorded into the history. Topics are not moved
+// between tables.
+func (db *SQLiteDB) SaveTopics(forumId uint, topics map[uint]*models.Topic, isArchived bool) (err error) {
+	return saveTopicsInTx(db.conn, db.preparedStatements, topicsSave{isArchived: isArchived}, func(sts *topicStatements) (err error) {
+		_, err = updateTopics(sts, topics, upsertTopicArgs)
+		return err
+	})
+}
+
+// MarkMissingTopics marks topics of a forum which have not been seen since the
+// start of a full crawl of the forum.
+func (db *SQLiteDB) MarkMissingTopics(forumId uint, crawlStartedAt time.Time, isArchived bool) (count int64, err error) {
+	var st *sql.Stmt
+	if isArchived {
+		st = db.preparedStatements[PreparedStatementIdx_QueryMarkMissingArchivedTopics]
+	} else {
+		st = db.preparedStatements[PreparedStatementIdx_QueryMarkMissingTopics]
+	}
+
+	var result sql.Result
+	result, err = st.Exec(time.Now().UTC().Truncate(time.Second), forumId, crawlStartedAt)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
+// KeepsTopicState tells that the database keeps the state of topics.
+func (db *SQLiteDB) KeepsTopicState() bool {
+	return true
+}
+
+// GetTopicHistory reads changes of a topic.
+func (db *SQLiteDB) GetTopicHistory(topicId uint) (changes []*models.TopicChange, err error) {
+	return getTopicHistory(db.conn, QuerySQLiteSelectTopicHistory, topicId)
+}
@@ -0,0 +1,101 @@
+package db
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/vault-thirteen/Forum-Crawler/src/models"
+)
+
+const (
+	ErrUnsupportedDriver = "unsupported database driver: %v"
+)
+
+// Storage is a storage for forums and topics.
+type Storage interface {
+	Close() (err error)
+	SaveForum(forum *models.Forum) (err error)
+	SaveTopic(topic *models.Topic, isArchived bool) (err error)
+	SaveNewTopic(topic *models.Topic, isArchived bool) (err error)
+	SaveTopics(forumId uint, topics map[uint]*models.Topic, isArchived bool) (err error)
+	UpdateTopics(topics map[uint]*models.Topic, isArchived bool) (stats *models.TopicsUpdateStats, err error)
+	MarkMissingTopics(forumId uint, crawlStartedAt time.Time, isArchived bool) (count int64, err error)
+}
+
+// Migratable is a storage having a versioned schema.
+type Migratable interface {
+	MigrateUp() (appliedCount int, err error)
+	MigrationStatus() (statuses []*MigrationStatus, err error)
+}
+
+// TopicHistoryReader is a storage keeping the history of topics.
+type TopicHistoryReader interface {
+	GetTopicHistory(topicId uint) (changes []*models.TopicChange, err error)
+}
+
+// TopicStateKeeper is a storage keeping the state of topics, i.e. a database.
+// Such storages can tell added topics from updated ones and count missing
+// topics.
+type TopicStateKeeper interface {
+	KeepsTopicState() bool
+}
+
+// CrawlStateStore is a storage keeping checkpoints of crawls.
+type CrawlStateStore interface {
+	GetUnfinishedCrawlRun(kind string) (run *models.CrawlRun, err error)
+	InsertCrawlRun(run *models.CrawlRun) (err error)
+	UpdateCrawlRun(run *models.CrawlRun) (err error)
+	GetCrawlStates(runId string) (states map[uint]*models.CrawlState, err error)
+	SaveCrawlState(state *models.CrawlState) (err error)
+}
+
+// NewStorage creates a storage using the driver selected in settings.
+func NewStorage(settings *models.DatabaseSettings) (s Storage, err error) {
+	switch settings.Driver {
+	case models.DatabaseDriver_MySQL:
+		var mysqlDb *DB
+		mysqlDb, err = NewDB(settings)
+		if err != nil {
+			return nil, err
+		}
+		return mysqlDb, nil
+
+	case models.DatabaseDriver_SQLite:
+		var sqliteDb *SQLiteDB
+		sqliteDb, err = NewSQLiteDB(settings)
+		if err != nil {
+			return nil, err
+		}
+		return sqliteDb, nil
+
+	case models.DatabaseDriver_Postgres:
+		var postgresDb *PostgresDB
+		postgresDb, err = NewPostgresDB(settings)
+		if err != nil {
+			return nil, err
+		}
+		return postgresDb, nil
+
+	default:
+		return nil, fmt.Errorf(ErrUnsupportedDriver, settings.Driver)
+	}
+}
+
+// FindCrawlStateStore returns a storage keeping checkpoints of crawls.
+// Storages combined by a MultiStorage are searched in turn.
+func FindCrawlStateStore(s Storage) (css CrawlStateStore, ok bool) {
+	ms, isMulti := s.(*MultiStorage)
+	if !isMulti {
+		css, ok = s.(CrawlStateStore)
+		return css, ok
+	}
+
+	for _, storage := range ms.storages {
+		css, ok = FindCrawlStateStore(storage)
+		if ok {
+			return css, true
+		}
+	}
+
+	return nil, false
+}
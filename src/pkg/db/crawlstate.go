@@ -0,0 +1,104 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/vault-thirteen/Forum-Crawler/src/models"
+	ae "github.com/vault-thirteen/auxie/errors"
+)
+
+// CrawlStateQueries are queries of crawl checkpoints written in a dialect of
+// a database.
+type CrawlStateQueries struct {
+	SelectUnfinishedRun string
+	InsertRun           string
+	UpdateRun           string
+	SelectStates        string
+	UpsertState         string
+
+	// UpsertStateArgs returns arguments of the upsert statement, which differ
+	// between dialects.
+	UpsertStateArgs func(state *models.CrawlState) []any
+}
+
+// crawlStateStore keeps crawl checkpoints in a database. It is shared by all
+// SQL databases.
+type crawlStateStore struct {
+	conn    *sql.DB
+	queries CrawlStateQueries
+}
+
+// upsertCrawlStateArgs returns arguments of an upsert which refers to the
+// inserted values.
+func upsertCrawlStateArgs(state *models.CrawlState) []any {
+	return []any{state.RunId, state.ForumId, state.LastPage, state.PageCount, state.Status, state.StartedAt, state.UpdatedAt}
+}
+
+// mysqlUpsertCrawlStateArgs returns arguments of a MySQL upsert, where updated
+// values are passed again.
+func mysqlUpsertCrawlStateArgs(state *models.CrawlState) []any {
+	return append(upsertCrawlStateArgs(state), state.LastPage, state.PageCount, state.Status, state.UpdatedAt)
+}
+
+// GetUnfinishedCrawlRun returns the latest unfinished run of the kind or nil.
+func (s *crawlStateStore) GetUnfinishedCrawlRun(kind string) (run *models.CrawlRun, err error) {
+	run = &models.CrawlRun{}
+	err = s.conn.QueryRow(s.queries.SelectUnfinishedRun, kind, models.CrawlStatus_InProgress).
+		Scan(&run.Id, &run.Kind, &run.Status, &run.StartedAt, &run.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return run, nil
+}
+
+func (s *crawlStateStore) InsertCrawlRun(run *models.CrawlRun) (err error) {
+	_, err = s.conn.Exec(s.queries.InsertRun, run.Id, run.Kind, run.Status, run.StartedAt, run.UpdatedAt)
+	return err
+}
+
+func (s *crawlStateStore) UpdateCrawlRun(run *models.CrawlRun) (err error) {
+	_, err = s.conn.Exec(s.queries.UpdateRun, run.Status, run.UpdatedAt, run.Id)
+	return err
+}
+
+// GetCrawlStates reads checkpoints of all forums of a run.
+func (s *crawlStateStore) GetCrawlStates(runId string) (states map[uint]*models.CrawlState, err error) {
+	var rows *sql.Rows
+	rows, err = s.conn.Query(s.queries.SelectStates, runId)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		derr := rows.Close()
+		if derr != nil {
+			err = ae.Combine(err, derr)
+		}
+	}()
+
+	states = make(map[uint]*models.CrawlState)
+	for rows.Next() {
+		cs := &models.CrawlState{}
+		err = rows.Scan(&cs.RunId, &cs.ForumId, &cs.LastPage, &cs.PageCount, &cs.Status, &cs.StartedAt, &cs.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+		states[cs.ForumId] = cs
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+
+	return states, nil
+}
+
+func (s *crawlStateStore) SaveCrawlState(state *models.CrawlState) (err error) {
+	_, err = s.conn.Exec(s.queries.UpsertState, s.queries.UpsertStateArgs(state)...)
+	return err
+}
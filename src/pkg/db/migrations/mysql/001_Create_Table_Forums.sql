@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS Forums (
+  ID INT UNSIGNED NOT NULL,
+  Name VARCHAR(1024) NOT NULL,
+  PRIMARY KEY (ID),
+  UNIQUE INDEX ID_UNIQUE (ID ASC) VISIBLE
+)
+ENGINE = InnoDB
+DEFAULT CHARACTER SET = utf8mb4
+COLLATE = utf8mb4_unicode_ci;
@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS TopicsArchived (
+  ID INT UNSIGNED NOT NULL,
+  Name VARCHAR(1024) NOT NULL,
+  ForumId INT UNSIGNED NOT NULL,
+  PRIMARY KEY (ID),
+  UNIQUE INDEX ID_UNIQUE (ID ASC) VISIBLE,
+  INDEX ForumId_Index (ForumId)
+)
+ENGINE = InnoDB
+DEFAULT CHARACTER SET = utf8mb4
+COLLATE = utf8mb4_unicode_ci;
@@ -0,0 +1,16 @@
+-- Tables created by older versions use the 3-byte 'utf8' character set.
+-- Full-length B-tree indices of names created by older scripts are too long
+-- for 'utf8mb4', so they are dropped and re-created by the next migration.
+SET @q = (SELECT IF(COUNT(*) > 0, 'DROP INDEX Topics_Name_BTIDX ON Topics', 'DO 0') FROM information_schema.STATISTICS WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = 'Topics' AND INDEX_NAME = 'Topics_Name_BTIDX');
+PREPARE stmt FROM @q;
+EXECUTE stmt;
+DEALLOCATE PREPARE stmt;
+
+SET @q = (SELECT IF(COUNT(*) > 0, 'DROP INDEX TopicsArchived_Name_BTIDX ON TopicsArchived', 'DO 0') FROM information_schema.STATISTICS WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = 'TopicsArchived' AND INDEX_NAME = 'TopicsArchived_Name_BTIDX');
+PREPARE stmt FROM @q;
+EXECUTE stmt;
+DEALLOCATE PREPARE stmt;
+
+ALTER TABLE Forums CONVERT TO CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci;
+ALTER TABLE Topics CONVERT TO CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci;
+ALTER TABLE TopicsArchived CONVERT TO CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci;
@@ -0,0 +1,22 @@
+-- Indices may already exist if they were created by older scripts.
+-- B-tree indices use a prefix, as 4-byte UTF-8 names may exceed the limit of
+-- 3072 bytes.
+SET @q = (SELECT IF(COUNT(*) = 0, 'CREATE FULLTEXT INDEX Topics_Name_FTIDX ON Topics (Name)', 'DO 0') FROM information_schema.STATISTICS WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = 'Topics' AND INDEX_NAME = 'Topics_Name_FTIDX');
+PREPARE stmt FROM @q;
+EXECUTE stmt;
+DEALLOCATE PREPARE stmt;
+
+SET @q = (SELECT IF(COUNT(*) = 0, 'CREATE INDEX Topics_Name_BTIDX USING BTREE ON Topics (Name(768))', 'DO 0') FROM information_schema.STATISTICS WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = 'Topics' AND INDEX_NAME = 'Topics_Name_BTIDX');
+PREPARE stmt FROM @q;
+EXECUTE stmt;
+DEALLOCATE PREPARE stmt;
+
+SET @q = (SELECT IF(COUNT(*) = 0, 'CREATE FULLTEXT INDEX TopicsArchived_Name_FTIDX ON TopicsArchived (Name)', 'DO 0') FROM information_schema.STATISTICS WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = 'TopicsArchived' AND INDEX_NAME = 'TopicsArchived_Name_FTIDX');
+PREPARE stmt FROM @q;
+EXECUTE stmt;
+DEALLOCATE PREPARE stmt;
+
+SET @q = (SELECT IF(COUNT(*) = 0, 'CREATE INDEX TopicsArchived_Name_BTIDX USING BTREE ON TopicsArchived (Name(768))', 'DO 0') FROM information_schema.STATISTICS WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = 'TopicsArchived' AND INDEX_NAME = 'TopicsArchived_Name_BTIDX');
+PREPARE stmt FROM @q;
+EXECUTE stmt;
+DEALLOCATE PREPARE stmt;
@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS TopicHistory (
+  ID BIGINT UNSIGNED NOT NULL AUTO_INCREMENT,
+  TopicId INT UNSIGNED NOT NULL,
+  OldName VARCHAR(1024) NOT NULL,
+  NewName VARCHAR(1024) NOT NULL,
+  OldForumId INT UNSIGNED NOT NULL,
+  NewForumId INT UNSIGNED NOT NULL,
+  ObservedAt DATETIME NOT NULL,
+  PRIMARY KEY (ID),
+  INDEX TopicId_Index (TopicId)
+)
+ENGINE = InnoDB
+DEFAULT CHARACTER SET = utf8mb4
+COLLATE = utf8mb4_unicode_ci;
@@ -0,0 +1,14 @@
+-- 'MissingSince' is set when a topic is not found by a full crawl of its
+-- forum and is cleared when the topic is seen again.
+-- DDL statements commit implicitly, so that each table is altered only when
+-- its columns do not exist yet. This makes a partially applied migration safe
+-- to re-run.
+SET @q = (SELECT IF(COUNT(*) = 0, 'ALTER TABLE Topics ADD COLUMN FirstSeenAt DATETIME NULL, ADD COLUMN LastSeenAt DATETIME NULL, ADD COLUMN MissingSince DATETIME NULL', 'DO 0') FROM information_schema.COLUMNS WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = 'Topics' AND COLUMN_NAME = 'FirstSeenAt');
+PREPARE stmt FROM @q;
+EXECUTE stmt;
+DEALLOCATE PREPARE stmt;
+
+SET @q = (SELECT IF(COUNT(*) = 0, 'ALTER TABLE TopicsArchived ADD COLUMN FirstSeenAt DATETIME NULL, ADD COLUMN LastSeenAt DATETIME NULL, ADD COLUMN MissingSince DATETIME NULL', 'DO 0') FROM information_schema.COLUMNS WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = 'TopicsArchived' AND COLUMN_NAME = 'FirstSeenAt');
+PREPARE stmt FROM @q;
+EXECUTE stmt;
+DEALLOCATE PREPARE stmt;
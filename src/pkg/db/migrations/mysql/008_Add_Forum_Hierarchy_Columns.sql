@@ -0,0 +1,6 @@
+-- The table is altered only when its columns do not exist yet, so that the
+-- migration is safe to re-run.
+SET @q = (SELECT IF(COUNT(*) = 0, 'ALTER TABLE Forums ADD COLUMN CategoryId INT UNSIGNED NOT NULL DEFAULT 0, ADD COLUMN CategoryName VARCHAR(1024) NOT NULL DEFAULT '''', ADD COLUMN ParentId INT UNSIGNED NOT NULL DEFAULT 0, ADD COLUMN SortOrder INT UNSIGNED NOT NULL DEFAULT 0, ADD COLUMN Depth INT UNSIGNED NOT NULL DEFAULT 0, ADD INDEX CategoryId_Index (CategoryId), ADD INDEX ParentId_Index (ParentId)', 'DO 0') FROM information_schema.COLUMNS WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = 'Forums' AND COLUMN_NAME = 'CategoryId');
+PREPARE stmt FROM @q;
+EXECUTE stmt;
+DEALLOCATE PREPARE stmt;
@@ -0,0 +1,27 @@
+-- Checkpoints of crawls. 'CrawlRun' lists runs, 'CrawlState' stores the last
+-- saved page of each forum of a run.
+CREATE TABLE IF NOT EXISTS CrawlRun (
+  RunId VARCHAR(64) NOT NULL,
+  Kind VARCHAR(255) NOT NULL,
+  Status VARCHAR(16) NOT NULL,
+  StartedAt DATETIME NOT NULL,
+  UpdatedAt DATETIME NOT NULL,
+  PRIMARY KEY (RunId),
+  INDEX Kind_Status_Index (Kind, Status)
+)
+ENGINE = InnoDB
+DEFAULT CHARACTER SET = utf8mb4
+COLLATE = utf8mb4_unicode_ci;
+CREATE TABLE IF NOT EXISTS CrawlState (
+  RunId VARCHAR(64) NOT NULL,
+  ForumId INT UNSIGNED NOT NULL,
+  LastPage INT UNSIGNED NOT NULL,
+  PageCount INT UNSIGNED NOT NULL,
+  Status VARCHAR(16) NOT NULL,
+  StartedAt DATETIME NOT NULL,
+  UpdatedAt DATETIME NOT NULL,
+  PRIMARY KEY (RunId, ForumId)
+)
+ENGINE = InnoDB
+DEFAULT CHARACTER SET = utf8mb4
+COLLATE = utf8mb4_unicode_ci;
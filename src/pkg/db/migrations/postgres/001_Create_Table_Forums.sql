@@ -0,0 +1,5 @@
+CREATE TABLE IF NOT EXISTS Forums (
+  ID BIGINT NOT NULL,
+  Name VARCHAR(1024) NOT NULL,
+  PRIMARY KEY (ID)
+);
@@ -0,0 +1,10 @@
+-- Full text search is done using a generated 'tsvector' column and a GIN
+-- index instead of MySQL 'FULLTEXT' indices.
+CREATE TABLE IF NOT EXISTS Topics (
+  ID BIGINT NOT NULL,
+  Name VARCHAR(1024) NOT NULL,
+  ForumId BIGINT NOT NULL,
+  NameTsv TSVECTOR GENERATED ALWAYS AS (to_tsvector({TextSearchConfig}::regconfig, Name)) STORED,
+  PRIMARY KEY (ID)
+);
+CREATE INDEX IF NOT EXISTS Topics_ForumId_Index ON Topics (ForumId);
@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS TopicsArchived (
+  ID BIGINT NOT NULL,
+  Name VARCHAR(1024) NOT NULL,
+  ForumId BIGINT NOT NULL,
+  NameTsv TSVECTOR GENERATED ALWAYS AS (to_tsvector({TextSearchConfig}::regconfig, Name)) STORED,
+  PRIMARY KEY (ID)
+);
+CREATE INDEX IF NOT EXISTS TopicsArchived_ForumId_Index ON TopicsArchived (ForumId);
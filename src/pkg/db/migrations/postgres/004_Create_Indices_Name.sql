@@ -0,0 +1,4 @@
+CREATE INDEX IF NOT EXISTS Topics_Name_FTIDX ON Topics USING GIN (NameTsv);
+CREATE INDEX IF NOT EXISTS Topics_Name_BTIDX ON Topics (Name);
+CREATE INDEX IF NOT EXISTS TopicsArchived_Name_FTIDX ON TopicsArchived USING GIN (NameTsv);
+CREATE INDEX IF NOT EXISTS TopicsArchived_Name_BTIDX ON TopicsArchived (Name);
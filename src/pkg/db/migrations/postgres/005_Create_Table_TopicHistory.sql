@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS TopicHistory (
+  ID BIGSERIAL NOT NULL,
+  TopicId BIGINT NOT NULL,
+  OldName VARCHAR(1024) NOT NULL,
+  NewName VARCHAR(1024) NOT NULL,
+  OldForumId BIGINT NOT NULL,
+  NewForumId BIGINT NOT NULL,
+  ObservedAt TIMESTAMP WITH TIME ZONE NOT NULL,
+  PRIMARY KEY (ID)
+);
+CREATE INDEX IF NOT EXISTS TopicHistory_TopicId_Index ON TopicHistory (TopicId);
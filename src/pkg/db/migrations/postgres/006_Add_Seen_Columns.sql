@@ -0,0 +1,10 @@
+-- 'MissingSince' is set when a topic is not found by a full crawl of its
+-- forum and is cleared when the topic is seen again.
+ALTER TABLE Topics
+  ADD COLUMN IF NOT EXISTS FirstSeenAt TIMESTAMP WITH TIME ZONE NULL,
+  ADD COLUMN IF NOT EXISTS LastSeenAt TIMESTAMP WITH TIME ZONE NULL,
+  ADD COLUMN IF NOT EXISTS MissingSince TIMESTAMP WITH TIME ZONE NULL;
+ALTER TABLE TopicsArchived
+  ADD COLUMN IF NOT EXISTS FirstSeenAt TIMESTAMP WITH TIME ZONE NULL,
+  ADD COLUMN IF NOT EXISTS LastSeenAt TIMESTAMP WITH TIME ZONE NULL,
+  ADD COLUMN IF NOT EXISTS MissingSince TIMESTAMP WITH TIME ZONE NULL;
@@ -0,0 +1,8 @@
+ALTER TABLE Forums
+  ADD COLUMN IF NOT EXISTS CategoryId BIGINT NOT NULL DEFAULT 0,
+  ADD COLUMN IF NOT EXISTS CategoryName VARCHAR(1024) NOT NULL DEFAULT '',
+  ADD COLUMN IF NOT EXISTS ParentId BIGINT NOT NULL DEFAULT 0,
+  ADD COLUMN IF NOT EXISTS SortOrder BIGINT NOT NULL DEFAULT 0,
+  ADD COLUMN IF NOT EXISTS Depth BIGINT NOT NULL DEFAULT 0;
+CREATE INDEX IF NOT EXISTS Forums_CategoryId_Index ON Forums (CategoryId);
+CREATE INDEX IF NOT EXISTS Forums_ParentId_Index ON Forums (ParentId);
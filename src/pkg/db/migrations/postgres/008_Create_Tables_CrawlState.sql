@@ -0,0 +1,21 @@
+-- Checkpoints of crawls. 'CrawlRun' lists runs, 'CrawlState' stores the last
+-- saved page of each forum of a run.
+CREATE TABLE IF NOT EXISTS CrawlRun (
+  RunId VARCHAR(64) NOT NULL,
+  Kind VARCHAR(255) NOT NULL,
+  Status VARCHAR(16) NOT NULL,
+  StartedAt TIMESTAMP WITH TIME ZONE NOT NULL,
+  UpdatedAt TIMESTAMP WITH TIME ZONE NOT NULL,
+  PRIMARY KEY (RunId)
+);
+CREATE INDEX IF NOT EXISTS CrawlRun_Kind_Status_Index ON CrawlRun (Kind, Status);
+CREATE TABLE IF NOT EXISTS CrawlState (
+  RunId VARCHAR(64) NOT NULL,
+  ForumId BIGINT NOT NULL,
+  LastPage BIGINT NOT NULL,
+  PageCount BIGINT NOT NULL,
+  Status VARCHAR(16) NOT NULL,
+  StartedAt TIMESTAMP WITH TIME ZONE NOT NULL,
+  UpdatedAt TIMESTAMP WITH TIME ZONE NOT NULL,
+  PRIMARY KEY (RunId, ForumId)
+);
@@ -0,0 +1,5 @@
+CREATE TABLE IF NOT EXISTS Forums (
+  ID INTEGER NOT NULL,
+  Name TEXT NOT NULL,
+  PRIMARY KEY (ID)
+);
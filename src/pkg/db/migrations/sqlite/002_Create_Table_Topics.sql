@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS Topics (
+  ID INTEGER NOT NULL,
+  Name TEXT NOT NULL,
+  ForumId INTEGER NOT NULL,
+  PRIMARY KEY (ID)
+);
+CREATE INDEX IF NOT EXISTS Topics_ForumId_Index ON Topics (ForumId);
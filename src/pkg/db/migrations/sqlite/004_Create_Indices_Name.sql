@@ -0,0 +1,2 @@
+CREATE INDEX IF NOT EXISTS Topics_Name_BTIDX ON Topics (Name);
+CREATE INDEX IF NOT EXISTS TopicsArchived_Name_BTIDX ON TopicsArchived (Name);
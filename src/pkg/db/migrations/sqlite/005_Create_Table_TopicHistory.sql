@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS TopicHistory (
+  ID INTEGER NOT NULL,
+  TopicId INTEGER NOT NULL,
+  OldName TEXT NOT NULL,
+  NewName TEXT NOT NULL,
+  OldForumId INTEGER NOT NULL,
+  NewForumId INTEGER NOT NULL,
+  ObservedAt DATETIME NOT NULL,
+  PRIMARY KEY (ID)
+);
+CREATE INDEX IF NOT EXISTS TopicHistory_TopicId_Index ON TopicHistory (TopicId);
@@ -0,0 +1,8 @@
+-- 'MissingSince' is set when a topic is not found by a full crawl of its
+-- forum and is cleared when the topic is seen again.
+ALTER TABLE Topics ADD COLUMN FirstSeenAt DATETIME NULL;
+ALTER TABLE Topics ADD COLUMN LastSeenAt DATETIME NULL;
+ALTER TABLE Topics ADD COLUMN MissingSince DATETIME NULL;
+ALTER TABLE TopicsArchived ADD COLUMN FirstSeenAt DATETIME NULL;
+ALTER TABLE TopicsArchived ADD COLUMN LastSeenAt DATETIME NULL;
+ALTER TABLE TopicsArchived ADD COLUMN MissingSince DATETIME NULL;
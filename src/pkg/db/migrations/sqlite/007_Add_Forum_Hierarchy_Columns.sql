@@ -0,0 +1,7 @@
+ALTER TABLE Forums ADD COLUMN CategoryId INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE Forums ADD COLUMN CategoryName TEXT NOT NULL DEFAULT '';
+ALTER TABLE Forums ADD COLUMN ParentId INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE Forums ADD COLUMN SortOrder INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE Forums ADD COLUMN Depth INTEGER NOT NULL DEFAULT 0;
+CREATE INDEX IF NOT EXISTS Forums_CategoryId_Index ON Forums (CategoryId);
+CREATE INDEX IF NOT EXISTS Forums_ParentId_Index ON Forums (ParentId);
@@ -0,0 +1,21 @@
+-- Checkpoints of crawls. 'CrawlRun' lists runs, 'CrawlState' stores the last
+-- saved page of each forum of a run.
+CREATE TABLE IF NOT EXISTS CrawlRun (
+  RunId TEXT NOT NULL,
+  Kind TEXT NOT NULL,
+  Status TEXT NOT NULL,
+  StartedAt DATETIME NOT NULL,
+  UpdatedAt DATETIME NOT NULL,
+  PRIMARY KEY (RunId)
+);
+CREATE INDEX IF NOT EXISTS CrawlRun_Kind_Status_Index ON CrawlRun (Kind, Status);
+CREATE TABLE IF NOT EXISTS CrawlState (
+  RunId TEXT NOT NULL,
+  ForumId INTEGER NOT NULL,
+  LastPage INTEGER NOT NULL,
+  PageCount INTEGER NOT NULL,
+  Status TEXT NOT NULL,
+  StartedAt DATETIME NOT NULL,
+  UpdatedAt DATETIME NOT NULL,
+  PRIMARY KEY (RunId, ForumId)
+);
@@ -6,43 +6,85 @@ import (
 )
 
 const (
-	QueryCreateForumsTable = `CREATE TABLE IF NOT EXISTS Forums (
-  ID INT UNSIGNED NOT NULL,
-  Name VARCHAR(1024) NOT NULL,
-  PRIMARY KEY (ID),
-  UNIQUE INDEX ID_UNIQUE (ID ASC) VISIBLE
-) 
-ENGINE = InnoDB
-DEFAULT CHARACTER SET = utf8;`
-
-	QueryCreateTopicsTable = `CREATE TABLE IF NOT EXISTS Topics (
-  ID INT UNSIGNED NOT NULL,
-  Name VARCHAR(1024) NOT NULL,
-  ForumId INT UNSIGNED NOT NULL,
-  PRIMARY KEY (ID),
-  UNIQUE INDEX ID_UNIQUE (ID ASC) VISIBLE,
-  INDEX ForumId_Index (ForumId)
+	QueryCreateSchemaVersionTable = `CREATE TABLE IF NOT EXISTS SchemaVersion (
+  Version INT UNSIGNED NOT NULL,
+  Name VARCHAR(255) NOT NULL,
+  AppliedAt DATETIME NOT NULL,
+  PRIMARY KEY (Version)
 )
 ENGINE = InnoDB
-DEFAULT CHARACTER SET = utf8;`
+DEFAULT CHARACTER SET = utf8mb4
+COLLATE = utf8mb4_unicode_ci;`
+
+	QuerySelectSchemaVersions = `SELECT Version, AppliedAt FROM SchemaVersion;`
+	QueryInsertSchemaVersion  = `INSERT INTO SchemaVersion (Version, Name, AppliedAt) VALUES (?, ?, ?);`
 
-	QueryUpsertForum = `INSERT INTO Forums (ID, Name) VALUES (?, ?) ON DUPLICATE KEY UPDATE ID=?, Name=?;`
+	QueryUpsertForum = `INSERT INTO Forums (ID, Name, CategoryId, CategoryName, ParentId, SortOrder, Depth) VALUES (?, ?, ?, ?, ?, ?, ?) ON DUPLICATE KEY UPDATE ID=?, Name=?, CategoryId=?, CategoryName=?, ParentId=?, SortOrder=?, Depth=?;`
 	//QueryUpsertForum = `REPLACE INTO Forums (ID, Name) VALUES (?, ?);` // REPLACE is bugged in MySQL.
 
-	QueryUpsertTopic = `INSERT INTO Topics (ID, Name, ForumId) VALUES (?, ?, ?) ON DUPLICATE KEY UPDATE ID=?, Name=?, ForumId=?;`
+	QueryUpsertTopic = `INSERT INTO Topics (ID, Name, ForumId, FirstSeenAt, LastSeenAt) VALUES (?, ?, ?, ?, ?) ON DUPLICATE KEY UPDATE ID=?, Name=?, ForumId=?, LastSeenAt=?, MissingSince=NULL;`
 	//QueryUpsertTopic = `REPLACE INTO Topics (ID, Name, ForumId) VALUES (?, ?, ?);` // REPLACE is bugged in MySQL.
 
-	QueryInsertNewTopic         = `INSERT IGNORE INTO Topics (ID, Name, ForumId) VALUES (?, ?, ?);`
-	QueryInsertNewArchivedTopic = `INSERT IGNORE INTO TopicsArchived (ID, Name, ForumId) VALUES (?, ?, ?);`
+	QueryUpsertArchivedTopic = `INSERT INTO TopicsArchived (ID, Name, ForumId, FirstSeenAt, LastSeenAt) VALUES (?, ?, ?, ?, ?) ON DUPLICATE KEY UPDATE ID=?, Name=?, ForumId=?, LastSeenAt=?, MissingSince=NULL;`
+
+	QuerySelectTopic         = `SELECT Name, ForumId, FirstSeenAt, LastSeenAt FROM Topics WHERE ID = ?;`
+	QuerySelectArchivedTopic = `SELECT Name, ForumId, FirstSeenAt, LastSeenAt FROM TopicsArchived WHERE ID = ?;`
+
+	// A topic moved into or out of the archive leaves a stale row in the other
+	// table.
+	QueryDeleteTopic         = `DELETE FROM Topics WHERE ID = ?;`
+	QueryDeleteArchivedTopic = `DELETE FROM TopicsArchived WHERE ID = ?;`
+
+	QuerySelectTopicsRange         = `SELECT ID, Name, ForumId FROM Topics WHERE ID BETWEEN ? AND ?;`
+	QuerySelectArchivedTopicsRange = `SELECT ID, Name, ForumId FROM TopicsArchived WHERE ID BETWEEN ? AND ?;`
+
+	QueryInsertTopicHistory = `INSERT INTO TopicHistory (TopicId, OldName, NewName, OldForumId, NewForumId, ObservedAt) VALUES (?, ?, ?, ?, ?, ?);`
+	QuerySelectTopicHistory = `SELECT TopicId, OldName, NewName, OldForumId, NewForumId, ObservedAt FROM TopicHistory WHERE TopicId = ? ORDER BY ObservedAt, ID;`
+
+	// New topics are inserted, existing topics are only marked as seen.
+	QueryInsertNewTopic         = `INSERT INTO Topics (ID, Name, ForumId, FirstSeenAt, LastSeenAt) VALUES (?, ?, ?, ?, ?) ON DUPLICATE KEY UPDATE LastSeenAt=?, MissingSince=NULL;`
+	QueryInsertNewArchivedTopic = `INSERT INTO TopicsArchived (ID, Name, ForumId, FirstSeenAt, LastSeenAt) VALUES (?, ?, ?, ?, ?) ON DUPLICATE KEY UPDATE LastSeenAt=?, MissingSince=NULL;`
+
+	QueryMarkMissingTopics         = `UPDATE Topics SET MissingSince=? WHERE ForumId=? AND MissingSince IS NULL AND (LastSeenAt IS NULL OR LastSeenAt < ?);`
+	QueryMarkMissingArchivedTopics = `UPDATE TopicsArchived SET MissingSince=? WHERE ForumId=? AND MissingSince IS NULL AND (LastSeenAt IS NULL OR LastSeenAt < ?);`
+
+	QuerySelectUnfinishedCrawlRun = `SELECT RunId, Kind, Status, StartedAt, UpdatedAt FROM CrawlRun WHERE Kind = ? AND Status = ? ORDER BY StartedAt DESC LIMIT 1;`
+	QueryInsertCrawlRun           = `INSERT INTO CrawlRun (RunId, Kind, Status, StartedAt, UpdatedAt) VALUES (?, ?, ?, ?, ?);`
+	QueryUpdateCrawlRun           = `UPDATE CrawlRun SET Status=?, UpdatedAt=? WHERE RunId=?;`
+	QuerySelectCrawlStates        = `SELECT RunId, ForumId, LastPage, PageCount, Status, StartedAt, UpdatedAt FROM CrawlState WHERE RunId = ?;`
+	QueryUpsertCrawlState         = `INSERT INTO CrawlState (RunId, ForumId, LastPage, PageCount, Status, StartedAt, UpdatedAt) VALUES (?, ?, ?, ?, ?, ?, ?) ON DUPLICATE KEY UPDATE LastPage=?, PageCount=?, Status=?, UpdatedAt=?;`
+
+	// QueryBulkUpsertTopicsSuffix ends a multi-row 'INSERT INTO Topics' query.
+	QueryBulkUpsertTopicsSuffix = `ON DUPLICATE KEY UPDATE Name=VALUES(Name), ForumId=VALUES(ForumId), LastSeenAt=VALUES(LastSeenAt), MissingSince=NULL;`
+
+	TableTopics         = "Topics"
+	TableTopicsArchived = "TopicsArchived"
 
 	BulkThresholdCount = 10
+	DefaultBatchSize   = 1000
+
+	// Collation of connections. It must match the character set of tables.
+	Collation = "utf8mb4_unicode_ci"
+
+	// DateTimeFormat is a format of 'DATETIME' literals in bulk queries.
+	DateTimeFormat = "2006-01-02 15:04:05"
 )
 
 const (
-	PreparedStatementIdx_QueryUpsertForum            = 0
-	PreparedStatementIdx_QueryUpsertTopic            = 1
-	PreparedStatementIdx_QueryInsertNewTopic         = 2
-	PreparedStatementIdx_QueryInsertNewArchivedTopic = 3
+	PreparedStatementIdx_QueryUpsertForum               = 0
+	PreparedStatementIdx_QueryUpsertTopic               = 1
+	PreparedStatementIdx_QueryInsertNewTopic            = 2
+	PreparedStatementIdx_QueryInsertNewArchivedTopic    = 3
+	PreparedStatementIdx_QueryUpsertArchivedTopic       = 4
+	PreparedStatementIdx_QuerySelectTopic               = 5
+	PreparedStatementIdx_QuerySelectArchivedTopic       = 6
+	PreparedStatementIdx_QueryInsertTopicHistory        = 7
+	PreparedStatementIdx_QuerySelectTopicsRange         = 8
+	PreparedStatementIdx_QueryMarkMissingTopics         = 9
+	PreparedStatementIdx_QueryMarkMissingArchivedTopics = 10
+	PreparedStatementIdx_QueryDeleteTopic               = 11
+	PreparedStatementIdx_QueryDeleteArchivedTopic       = 12
+	PreparedStatementIdx_QuerySelectArchivedTopicsRange = 13
 )
 
 func escapeString(s string) string {
@@ -0,0 +1,54 @@
+package db
+
+const (
+	QueryPostgresCreateSchemaVersionTable = `CREATE TABLE IF NOT EXISTS SchemaVersion (
+  Version BIGINT NOT NULL,
+  Name VARCHAR(255) NOT NULL,
+  AppliedAt TIMESTAMP WITH TIME ZONE NOT NULL,
+  PRIMARY KEY (Version)
+);`
+
+	QueryPostgresSelectSchemaVersions = `SELECT Version, AppliedAt FROM SchemaVersion;`
+	QueryPostgresInsertSchemaVersion  = `INSERT INTO SchemaVersion (Version, Name, AppliedAt) VALUES ($1, $2, $3);`
+
+	QueryPostgresUpsertForum = `INSERT INTO Forums (ID, Name, CategoryId, CategoryName, ParentId, SortOrder, Depth) VALUES ($1, $2, $3, $4, $5, $6, $7) ON CONFLICT (ID) DO UPDATE SET Name=EXCLUDED.Name, CategoryId=EXCLUDED.CategoryId, CategoryName=EXCLUDED.CategoryName, ParentId=EXCLUDED.ParentId, SortOrder=EXCLUDED.SortOrder, Depth=EXCLUDED.Depth;`
+
+	QueryPostgresUpsertTopic = `INSERT INTO Topics (ID, Name, ForumId, FirstSeenAt, LastSeenAt) VALUES ($1, $2, $3, $4, $5) ON CONFLICT (ID) DO UPDATE SET Name=EXCLUDED.Name, ForumId=EXCLUDED.ForumId, LastSeenAt=EXCLUDED.LastSeenAt, MissingSince=NULL;`
+
+	QueryPostgresUpsertArchivedTopic = `INSERT INTO TopicsArchived (ID, Name, ForumId, FirstSeenAt, LastSeenAt) VALUES ($1, $2, $3, $4, $5) ON CONFLICT (ID) DO UPDATE SET Name=EXCLUDED.Name, ForumId=EXCLUDED.ForumId, LastSeenAt=EXCLUDED.LastSeenAt, MissingSince=NULL;`
+
+	QueryPostgresSelectTopic         = `SELECT Name, ForumId, FirstSeenAt, LastSeenAt FROM Topics WHERE ID = $1;`
+	QueryPostgresSelectArchivedTopic = `SELECT Name, ForumId, FirstSeenAt, LastSeenAt FROM TopicsArchived WHERE ID = $1;`
+
+	// A topic moved into or out of the archive leaves a stale row in the other
+	// table.
+	QueryPostgresDeleteTopic         = `DELETE FROM Topics WHERE ID = $1;`
+	QueryPostgresDeleteArchivedTopic = `DELETE FROM TopicsArchived WHERE ID = $1;`
+
+	QueryPostgresSelectTopicsRange         = `SELECT ID, Name, ForumId FROM Topics WHERE ID BETWEEN $1 AND $2;`
+	QueryPostgresSelectArchivedTopicsRange = `SELECT ID, Name, ForumId FROM TopicsArchived WHERE ID BETWEEN $1 AND $2;`
+
+	QueryPostgresInsertTopicHistory = `INSERT INTO TopicHistory (TopicId, OldName, NewName, OldForumId, NewForumId, ObservedAt) VALUES ($1, $2, $3, $4, $5, $6);`
+	QueryPostgresSelectTopicHistory = `SELECT TopicId, OldName, NewName, OldForumId, NewForumId, ObservedAt FROM TopicHistory WHERE TopicId = $1 ORDER BY ObservedAt, ID;`
+
+	// New topics are inserted, existing topics are only marked as seen.
+	QueryPostgresInsertNewTopic         = `INSERT INTO Topics (ID, Name, ForumId, FirstSeenAt, LastSeenAt) VALUES ($1, $2, $3, $4, $5) ON CONFLICT (ID) DO UPDATE SET LastSeenAt=EXCLUDED.LastSeenAt, MissingSince=NULL;`
+	QueryPostgresInsertNewArchivedTopic = `INSERT INTO TopicsArchived (ID, Name, ForumId, FirstSeenAt, LastSeenAt) VALUES ($1, $2, $3, $4, $5) ON CONFLICT (ID) DO UPDATE SET LastSeenAt=EXCLUDED.LastSeenAt, MissingSince=NULL;`
+
+	QueryPostgresMarkMissingTopics         = `UPDATE Topics SET MissingSince=$1 WHERE ForumId=$2 AND MissingSince IS NULL AND (LastSeenAt IS NULL OR LastSeenAt < $3);`
+	QueryPostgresMarkMissingArchivedTopics = `UPDATE TopicsArchived SET MissingSince=$1 WHERE ForumId=$2 AND MissingSince IS NULL AND (LastSeenAt IS NULL OR LastSeenAt < $3);`
+
+	QueryPostgresSelectUnfinishedCrawlRun = `SELECT RunId, Kind, Status, StartedAt, UpdatedAt FROM CrawlRun WHERE Kind = $1 AND Status = $2 ORDER BY StartedAt DESC LIMIT 1;`
+	QueryPostgresInsertCrawlRun           = `INSERT INTO CrawlRun (RunId, Kind, Status, StartedAt, UpdatedAt) VALUES ($1, $2, $3, $4, $5);`
+	QueryPostgresUpdateCrawlRun           = `UPDATE CrawlRun SET Status=$1, UpdatedAt=$2 WHERE RunId=$3;`
+	QueryPostgresSelectCrawlStates        = `SELECT RunId, ForumId, LastPage, PageCount, Status, StartedAt, UpdatedAt FROM CrawlState WHERE RunId = $1;`
+	QueryPostgresUpsertCrawlState         = `INSERT INTO CrawlState (RunId, ForumId, LastPage, PageCount, Status, StartedAt, UpdatedAt) VALUES ($1, $2, $3, $4, $5, $6, $7) ON CONFLICT (RunId, ForumId) DO UPDATE SET LastPage=EXCLUDED.LastPage, PageCount=EXCLUDED.PageCount, Status=EXCLUDED.Status, UpdatedAt=EXCLUDED.UpdatedAt;`
+)
+
+const (
+	PostgresDefaultTextSearchConfig = "simple"
+
+	// PostgresTextSearchConfigPlaceholder is replaced in migrations with the
+	// text search configuration from settings.
+	PostgresTextSearchConfigPlaceholder = "{TextSearchConfig}"
+)
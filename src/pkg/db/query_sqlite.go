@@ -0,0 +1,50 @@
+package db
+
+const (
+	QuerySQLiteCreateSchemaVersionTable = `CREATE TABLE IF NOT EXISTS SchemaVersion (
+  Version INTEGER NOT NULL,
+  Name TEXT NOT NULL,
+  AppliedAt DATETIME NOT NULL,
+  PRIMARY KEY (Version)
+);`
+
+	QuerySQLiteSelectSchemaVersions = `SELECT Version, AppliedAt FROM SchemaVersion;`
+	QuerySQLiteInsertSchemaVersion  = `INSERT INTO SchemaVersion (Version, Name, AppliedAt) VALUES (?, ?, ?);`
+
+	QuerySQLiteUpsertForum = `INSERT INTO Forums (ID, Name, CategoryId, CategoryName, ParentId, SortOrder, Depth) VALUES (?, ?, ?, ?, ?, ?, ?) ON CONFLICT (ID) DO UPDATE SET Name=excluded.Name, CategoryId=excluded.CategoryId, CategoryName=excluded.CategoryName, ParentId=excluded.ParentId, SortOrder=excluded.SortOrder, Depth=excluded.Depth;`
+
+	QuerySQLiteUpsertTopic = `INSERT INTO Topics (ID, Name, ForumId, FirstSeenAt, LastSeenAt) VALUES (?, ?, ?, ?, ?) ON CONFLICT (ID) DO UPDATE SET Name=excluded.Name, ForumId=excluded.ForumId, LastSeenAt=excluded.LastSeenAt, MissingSince=NULL;`
+
+	QuerySQLiteUpsertArchivedTopic = `INSERT INTO TopicsArchived (ID, Name, ForumId, FirstSeenAt, LastSeenAt) VALUES (?, ?, ?, ?, ?) ON CONFLICT (ID) DO UPDATE SET Name=excluded.Name, ForumId=excluded.ForumId, LastSeenAt=excluded.LastSeenAt, MissingSince=NULL;`
+
+	QuerySQLiteSelectTopic         = `SELECT Name, ForumId, FirstSeenAt, LastSeenAt FROM Topics WHERE ID = ?;`
+	QuerySQLiteSelectArchivedTopic = `SELECT Name, ForumId, FirstSeenAt, LastSeenAt FROM TopicsArchived WHERE ID = ?;`
+
+	// A topic moved into or out of the archive leaves a stale row in the other
+	// table.
+	QuerySQLiteDeleteTopic         = `DELETE FROM Topics WHERE ID = ?;`
+	QuerySQLiteDeleteArchivedTopic = `DELETE FROM TopicsArchived WHERE ID = ?;`
+
+	QuerySQLiteSelectTopicsRange         = `SELECT ID, Name, ForumId FROM Topics WHERE ID BETWEEN ? AND ?;`
+	QuerySQLiteSelectArchivedTopicsRange = `SELECT ID, Name, ForumId FROM TopicsArchived WHERE ID BETWEEN ? AND ?;`
+
+	QuerySQLiteInsertTopicHistory = `INSERT INTO TopicHistory (TopicId, OldName, NewName, OldForumId, NewForumId, ObservedAt) VALUES (?, ?, ?, ?, ?, ?);`
+	QuerySQLiteSelectTopicHistory = `SELECT TopicId, OldName, NewName, OldForumId, NewForumId, ObservedAt FROM TopicHistory WHERE TopicId = ? ORDER BY ObservedAt, ID;`
+
+	// New topics are inserted, existing topics are only marked as seen.
+	QuerySQLiteInsertNewTopic         = `INSERT INTO Topics (ID, Name, ForumId, FirstSeenAt, LastSeenAt) VALUES (?, ?, ?, ?, ?) ON CONFLICT (ID) DO UPDATE SET LastSeenAt=excluded.LastSeenAt, MissingSince=NULL;`
+	QuerySQLiteInsertNewArchivedTopic = `INSERT INTO TopicsArchived (ID, Name, ForumId, FirstSeenAt, LastSeenAt) VALUES (?, ?, ?, ?, ?) ON CONFLICT (ID) DO UPDATE SET LastSeenAt=excluded.LastSeenAt, MissingSince=NULL;`
+
+	QuerySQLiteMarkMissingTopics         = `UPDATE Topics SET MissingSince=? WHERE ForumId=? AND MissingSince IS NULL AND (LastSeenAt IS NULL OR LastSeenAt < ?);`
+	QuerySQLiteMarkMissingArchivedTopics = `UPDATE TopicsArchived SET MissingSince=? WHERE ForumId=? AND MissingSince IS NULL AND (LastSeenAt IS NULL OR LastSeenAt < ?);`
+
+	QuerySQLiteSelectUnfinishedCrawlRun = `SELECT RunId, Kind, Status, StartedAt, UpdatedAt FROM CrawlRun WHERE Kind = ? AND Status = ? ORDER BY StartedAt DESC LIMIT 1;`
+	QuerySQLiteInsertCrawlRun           = `INSERT INTO CrawlRun (RunId, Kind, Status, StartedAt, UpdatedAt) VALUES (?, ?, ?, ?, ?);`
+	QuerySQLiteUpdateCrawlRun           = `UPDATE CrawlRun SET Status=?, UpdatedAt=? WHERE RunId=?;`
+	QuerySQLiteSelectCrawlStates        = `SELECT RunId, ForumId, LastPage, PageCount, Status, StartedAt, UpdatedAt FROM CrawlState WHERE RunId = ?;`
+	QuerySQLiteUpsertCrawlState         = `INSERT INTO CrawlState (RunId, ForumId, LastPage, PageCount, Status, StartedAt, UpdatedAt) VALUES (?, ?, ?, ?, ?, ?, ?) ON CONFLICT (RunId, ForumId) DO UPDATE SET LastPage=excluded.LastPage, PageCount=excluded.PageCount, Status=excluded.Status, UpdatedAt=excluded.UpdatedAt;`
+)
+
+const (
+	SQLiteBusyTimeoutMs = 5000
+)
@@ -0,0 +1,358 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/vault-thirteen/Forum-Crawler/src/models"
+	ae "github.com/vault-thirteen/auxie/errors"
+)
+
+// topicStatements are statements used by a save of topics. The other table
+// is the archive for usual topics and vice versa. Statements of the other
+// table are not set when topics must not be moved between tables.
+type topicStatements struct {
+	selectTopic      *sql.Stmt
+	upsertTopic      *sql.Stmt
+	selectOtherTopic *sql.Stmt
+	deleteOtherTopic *sql.Stmt
+	insertHistory    *sql.Stmt
+}
+
+// topicsSave selects statements of a save of topics. Topics are moved
+// between tables only by updates and refreshes, while an initial save writes
+// into the table of topics as is.
+type topicsSave struct {
+	isArchived bool
+	canMove    bool
+	onlyNew    bool
+}
+
+// saveTopicsInTx calls the function with statements of topics bound to a new
+// transaction. The transaction is committed when the function succeeds.
+func saveTopicsInTx(conn *sql.DB, prepared []*sql.Stmt, ts topicsSave, fn func(sts *topicStatements) (err error)) (err error) {
+	var tx *sql.Tx
+	tx, err = conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			derr := tx.Rollback()
+			if derr != nil {
+				err = ae.Combine(err, derr)
+			}
+		}
+	}()
+
+	selectIdx, otherSelectIdx, otherDeleteIdx := PreparedStatementIdx_QuerySelectTopic, PreparedStatementIdx_QuerySelectArchivedTopic, PreparedStatementIdx_QueryDeleteArchivedTopic
+	upsertIdx, insertNewIdx := PreparedStatementIdx_QueryUpsertTopic, PreparedStatementIdx_QueryInsertNewTopic
+	if ts.isArchived {
+		selectIdx, otherSelectIdx, otherDeleteIdx = PreparedStatementIdx_QuerySelectArchivedTopic, PreparedStatementIdx_QuerySelectTopic, PreparedStatementIdx_QueryDeleteTopic
+		upsertIdx, insertNewIdx = PreparedStatementIdx_QueryUpsertArchivedTopic, PreparedStatementIdx_QueryInsertNewArchivedTopic
+	}
+	if ts.onlyNew {
+		upsertIdx = insertNewIdx
+	}
+
+	sts := &topicStatements{
+		selectTopic:   tx.Stmt(prepared[selectIdx]),
+		upsertTopic:   tx.Stmt(prepared[upsertIdx]),
+		insertHistory: tx.Stmt(prepared[PreparedStatementIdx_QueryInsertTopicHistory]),
+	}
+	if ts.canMove {
+		sts.selectOtherTopic = tx.Stmt(prepared[otherSelectIdx])
+		sts.deleteOtherTopic = tx.Stmt(prepared[otherDeleteIdx])
+	}
+	defer func() {
+		for _, st := range []*sql.Stmt{sts.selectTopic, sts.upsertTopic, sts.insertHistory, sts.selectOtherTopic, sts.deleteOtherTopic} {
+			if st == nil {
+				continue
+			}
+			derr := st.Close()
+			if derr != nil {
+				err = ae.Combine(err, derr)
+			}
+		}
+	}()
+
+	err = fn(sts)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// storedTopic is a topic row read before it is overwritten.
+type storedTopic struct {
+	name        string
+	forumId     uint
+	firstSeenAt sql.NullTime
+	lastSeenAt  sql.NullTime
+}
+
+// updateTopics compares topics with existing rows, upserts topics and records
+// changes into the history. Unchanged topics are upserted too, so that the time
+// when they were last seen is updated. A topic found in the other table has
+// been moved into or out of the archive, its row is moved into the table of
+// topics. 'upsertArgs' returns arguments of the upsert statement, which differ
+// between dialects.
+func updateTopics(sts *topicStatements, topics map[uint]*models.Topic, upsertArgs func(topic *models.Topic) []any) (stats *models.TopicsUpdateStats, err error) {
+	stats = &models.TopicsUpdateStats{}
+	observedAt := time.Now().UTC()
+
+	var old *storedTopic
+	var ok bool
+	for _, topic := range topics {
+		old, ok, err = findStoredTopic(sts.selectTopic, topic.Id)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			old, ok, err = takeMovedTopic(sts, topic.Id)
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				topic = withStoredSeenTimes(topic, old)
+			}
+		}
+
+		_, err = sts.upsertTopic.Exec(upsertArgs(topic)...)
+		if err != nil {
+			return nil, err
+		}
+
+		if !ok {
+			stats.Added++
+			continue
+		}
+		if (old.name == topic.Name) && (old.forumId == topic.ForumId) {
+			stats.Unchanged++
+			continue
+		}
+		if old.name != topic.Name {
+			stats.Renamed++
+		}
+		if old.forumId != topic.ForumId {
+			stats.Moved++
+		}
+
+		err = recordTopicChange(sts.insertHistory, topic, old.name, old.forumId, observedAt)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return stats, nil
+}
+
+// saveNewTopic inserts a new topic, an existing topic is only marked as seen
+// by the insert statement. A topic found in the other table is moved into the
+// table of topics and its changes are recorded into the history. 'insertArgs'
+// returns arguments of the insert statement, which differ between dialects.
+func saveNewTopic(sts *topicStatements, topic *models.Topic, insertArgs func(topic *models.Topic) []any) (err error) {
+	_, ok, err := findStoredTopic(sts.selectTopic, topic.Id)
+	if err != nil {
+		return err
+	}
+
+	var old *storedTopic
+	if !ok {
+		old, ok, err = takeMovedTopic(sts, topic.Id)
+		if err != nil {
+			return err
+		}
+		if ok {
+			topic = withStoredSeenTimes(topic, old)
+		}
+	}
+
+	_, err = sts.upsertTopic.Exec(insertArgs(topic)...)
+	if err != nil {
+		return err
+	}
+
+	if (old == nil) || ((old.name == topic.Name) && (old.forumId == topic.ForumId)) {
+		return nil
+	}
+
+	return recordTopicChange(sts.insertHistory, topic, old.name, old.forumId, time.Now().UTC())
+}
+
+// findStoredTopic reads a topic row.
+func findStoredTopic(stSelect *sql.Stmt, topicId uint) (old *storedTopic, ok bool, err error) {
+	old = &storedTopic{}
+	err = stSelect.QueryRow(topicId).Scan(&old.name, &old.forumId, &old.firstSeenAt, &old.lastSeenAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	return old, true, nil
+}
+
+// takeMovedTopic reads a topic row from the other table and deletes it. Nothing
+// is done when topics are not moved between tables.
+func takeMovedTopic(sts *topicStatements, topicId uint) (old *storedTopic, ok bool, err error) {
+	if sts.selectOtherTopic == nil {
+		return nil, false, nil
+	}
+
+	old, ok, err = findStoredTopic(sts.selectOtherTopic, topicId)
+	if (err != nil) || !ok {
+		return nil, false, err
+	}
+
+	_, err = sts.deleteOtherTopic.Exec(topicId)
+	if err != nil {
+		return nil, false, err
+	}
+
+	return old, true, nil
+}
+
+// withStoredSeenTimes returns a copy of a moved topic keeping the time when
+// it was first seen and the latest time when it was last seen.
+func withStoredSeenTimes(topic *models.Topic, old *storedTopic) *models.Topic {
+	moved := *topic
+	moved.FirstSeenAt, moved.LastSeenAt = seenTimes(topic)
+
+	if old.firstSeenAt.Valid && old.firstSeenAt.Time.Before(moved.FirstSeenAt) {
+		moved.FirstSeenAt = old.firstSeenAt.Time.UTC()
+	}
+	if old.lastSeenAt.Valid && old.lastSeenAt.Time.After(moved.LastSeenAt) {
+		moved.LastSeenAt = old.lastSeenAt.Time.UTC()
+	}
+
+	return &moved
+}
+
+// recordTopicsChanges records changes of sorted topics into the history
+// before they are overwritten by a bulk query. Existing rows are read using a
+// single range query.
+func recordTopicsChanges(stSelectRange *sql.Stmt, stInsertHistory *sql.Stmt, sortedTopics []*models.Topic) (err error) {
+	if len(sortedTopics) == 0 {
+		return nil
+	}
+
+	topics := make(map[uint]*models.Topic, len(sortedTopics))
+	for _, topic := range sortedTopics {
+		topics[topic.Id] = topic
+	}
+
+	var rows *sql.Rows
+	rows, err = stSelectRange.Query(sortedTopics[0].Id, sortedTopics[len(sortedTopics)-1].Id)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		derr := rows.Close()
+		if derr != nil {
+			err = ae.Combine(err, derr)
+		}
+	}()
+
+	changes := make([]*models.TopicChange, 0)
+	observedAt := time.Now().UTC()
+	var id, oldForumId uint
+	var oldName string
+	for rows.Next() {
+		err = rows.Scan(&id, &oldName, &oldForumId)
+		if err != nil {
+			return err
+		}
+
+		topic, ok := topics[id]
+		if !ok || ((oldName == topic.Name) && (oldForumId == topic.ForumId)) {
+			continue
+		}
+
+		changes = append(changes, &models.TopicChange{
+			TopicId:    id,
+			OldName:    oldName,
+			NewName:    topic.Name,
+			OldForumId: oldForumId,
+			NewForumId: topic.ForumId,
+			ObservedAt: observedAt,
+		})
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return err
+	}
+
+	for _, c := range changes {
+		_, err = stInsertHistory.Exec(c.TopicId, c.OldName, c.NewName, c.OldForumId, c.NewForumId, c.ObservedAt)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// upsertTopicArgs returns arguments of the upsert or the insert of a topic in
+// dialects with 'ON CONFLICT' clauses.
+func upsertTopicArgs(topic *models.Topic) []any {
+	firstSeenAt, lastSeenAt := seenTimes(topic)
+	return []any{topic.Id, topic.Name, topic.ForumId, firstSeenAt, lastSeenAt}
+}
+
+// seenTimes returns the time when a topic was first and last seen. Topics
+// which have no time are treated as seen now.
+func seenTimes(topic *models.Topic) (firstSeenAt time.Time, lastSeenAt time.Time) {
+	now := time.Now().UTC().Truncate(time.Second)
+
+	firstSeenAt, lastSeenAt = topic.FirstSeenAt, topic.LastSeenAt
+	if firstSeenAt.IsZero() {
+		firstSeenAt = now
+	}
+	if lastSeenAt.IsZero() {
+		lastSeenAt = now
+	}
+
+	return firstSeenAt, lastSeenAt
+}
+
+func recordTopicChange(stInsertHistory *sql.Stmt, topic *models.Topic, oldName string, oldForumId uint, observedAt time.Time) (err error) {
+	_, err = stInsertHistory.Exec(topic.Id, oldName, topic.Name, oldForumId, topic.ForumId, observedAt)
+	return err
+}
+
+// getTopicHistory reads changes of a topic in the order of their observation.
+func getTopicHistory(conn *sql.DB, query string, topicId uint) (changes []*models.TopicChange, err error) {
+	var rows *sql.Rows
+	rows, err = conn.Query(query, topicId)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		derr := rows.Close()
+		if derr != nil {
+			err = ae.Combine(err, derr)
+		}
+	}()
+
+	changes = make([]*models.TopicChange, 0)
+	for rows.Next() {
+		c := &models.TopicChange{}
+		err = rows.Scan(&c.TopicId, &c.OldName, &c.NewName, &c.OldForumId, &c.NewForumId, &c.ObservedAt)
+		if err != nil {
+			return nil, err
+		}
+		changes = append(changes, c)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+
+	return changes, nil
+}
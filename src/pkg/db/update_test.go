@@ -0,0 +1,183 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vault-thirteen/Forum-Crawler/src/models"
+)
+
+const (
+	TestArchivedForumId = 100
+)
+
+func Test_SaveTopics_Init(t *testing.T) {
+	// Test #1. Topics of the archive are saved into the archive both one by
+	// one and in batches, while a topic of the same ID in the other table is
+	// left as is.
+	for _, isBatch := range []bool{false, true} {
+		db := mustOpenSQLiteDB(t)
+		seenAt := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+
+		mustSaveTopics(t, db, false, isBatch, &models.Topic{Id: 1, Name: "A", ForumId: 7, FirstSeenAt: seenAt, LastSeenAt: seenAt})
+		mustSaveTopics(t, db, true, isBatch, &models.Topic{Id: 1, Name: "A", ForumId: TestArchivedForumId})
+
+		mustBeEqual(t, mustFindTopic(t, db, TableTopics, 1), &storedTopic{name: "A", forumId: 7, firstSeenAt: nullTime(seenAt), lastSeenAt: nullTime(seenAt)})
+		archived := mustFindTopic(t, db, TableTopicsArchived, 1)
+		mustBeEqual(t, archived.forumId, uint(TestArchivedForumId))
+		mustBeEqual(t, len(mustGetTopicHistory(t, db, 1)), 0)
+	}
+
+	// Test #2. A saved topic is updated and its changes are recorded.
+	db := mustOpenSQLiteDB(t)
+	mustSaveTopics(t, db, false, true, &models.Topic{Id: 1, Name: "A", ForumId: 7})
+	mustSaveTopics(t, db, false, true, &models.Topic{Id: 1, Name: "B", ForumId: 7})
+	mustBeEqual(t, mustFindTopic(t, db, TableTopics, 1).name, "B")
+	mustBeEqual(t, len(mustGetTopicHistory(t, db, 1)), 1)
+}
+
+func Test_UpdateTopics_Move(t *testing.T) {
+	db := mustOpenSQLiteDB(t)
+	firstSeenAt := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	mustSaveTopics(t, db, false, false, &models.Topic{Id: 1, Name: "A", ForumId: 7, FirstSeenAt: firstSeenAt, LastSeenAt: firstSeenAt})
+
+	// Test #1. A topic found in the archive is moved out of the table of
+	// topics keeping the time when it was first seen.
+	stats, err := db.UpdateTopics(map[uint]*models.Topic{1: {Id: 1, Name: "A", ForumId: TestArchivedForumId}}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustBeEqual(t, stats, &models.TopicsUpdateStats{Moved: 1})
+	mustBeMissing(t, db, TableTopics, 1)
+	archived := mustFindTopic(t, db, TableTopicsArchived, 1)
+	mustBeEqual(t, archived.firstSeenAt, nullTime(firstSeenAt))
+	mustBeEqual(t, archived.lastSeenAt.Time.After(firstSeenAt), true)
+
+	history := mustGetTopicHistory(t, db, 1)
+	mustBeEqual(t, len(history), 1)
+	mustBeEqual(t, []uint{history[0].OldForumId, history[0].NewForumId}, []uint{7, TestArchivedForumId})
+
+	// Test #2. A new topic is moved back out of the archive.
+	err = db.SaveNewTopic(&models.Topic{Id: 1, Name: "A", ForumId: 8}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustBeMissing(t, db, TableTopicsArchived, 1)
+	mustBeEqual(t, mustFindTopic(t, db, TableTopics, 1).firstSeenAt, nullTime(firstSeenAt))
+	mustBeEqual(t, len(mustGetTopicHistory(t, db, 1)), 2)
+}
+
+func Test_buildBulkUpsertTopicsQuery(t *testing.T) {
+	topics := []*models.Topic{{Id: 1, Name: "It's", ForumId: 7}}
+
+	// Test #1. Usual topics.
+	query := buildBulkUpsertTopicsQuery(topics, false)
+	mustBeEqual(t, strings.HasPrefix(query, "INSERT INTO Topics "), true)
+	mustBeEqual(t, strings.Contains(query, `(1,'It''s',7,`), true)
+
+	// Test #2. Archived topics.
+	query = buildBulkUpsertTopicsQuery(topics, true)
+	mustBeEqual(t, strings.HasPrefix(query, "INSERT INTO TopicsArchived "), true)
+}
+
+func mustBeEqual(t *testing.T, actual any, expected any) {
+	t.Helper()
+
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("values are not equal:\nactual:   %#v\nexpected: %#v", actual, expected)
+	}
+}
+
+func mustOpenSQLiteDB(t *testing.T) (db *SQLiteDB) {
+	t.Helper()
+
+	db, err := NewSQLiteDB(&models.DatabaseSettings{File: filepath.Join(t.TempDir(), "test.db")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = db.Close()
+	})
+
+	return db
+}
+
+// mustSaveTopics saves topics the way 'init' does, one by one or in a batch.
+func mustSaveTopics(t *testing.T, db *SQLiteDB, isArchived bool, isBatch bool, topics ...*models.Topic) {
+	t.Helper()
+
+	var err error
+	for _, topic := range topics {
+		if isBatch {
+			err = db.SaveTopics(topic.ForumId, map[uint]*models.Topic{topic.Id: topic}, isArchived)
+		} else {
+			err = db.SaveTopic(topic, isArchived)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func mustFindTopic(t *testing.T, db *SQLiteDB, table string, topicId uint) (topic *storedTopic) {
+	t.Helper()
+
+	topic, ok, err := findTopicInTable(db, table, topicId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ok {
+		t.Fatalf("topic %v is not found in %v", topicId, table)
+	}
+
+	return topic
+}
+
+func mustBeMissing(t *testing.T, db *SQLiteDB, table string, topicId uint) {
+	t.Helper()
+
+	_, ok, err := findTopicInTable(db, table, topicId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok {
+		t.Errorf("topic %v is found in %v", topicId, table)
+	}
+}
+
+func findTopicInTable(db *SQLiteDB, table string, topicId uint) (topic *storedTopic, ok bool, err error) {
+	topic = &storedTopic{}
+	err = db.conn.QueryRow(`SELECT Name, ForumId, FirstSeenAt, LastSeenAt FROM `+table+` WHERE ID = ?;`, topicId).
+		Scan(&topic.name, &topic.forumId, &topic.firstSeenAt, &topic.lastSeenAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	topic.firstSeenAt.Time = topic.firstSeenAt.Time.UTC()
+	topic.lastSeenAt.Time = topic.lastSeenAt.Time.UTC()
+
+	return topic, true, nil
+}
+
+func mustGetTopicHistory(t *testing.T, db *SQLiteDB, topicId uint) (changes []*models.TopicChange) {
+	t.Helper()
+
+	changes, err := db.GetTopicHistory(topicId)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return changes
+}
+
+func nullTime(tm time.Time) sql.NullTime {
+	return sql.NullTime{Time: tm, Valid: true}
+}
@@ -0,0 +1,230 @@
+package discourse
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/vault-thirteen/auxie/number"
+)
+
+const (
+	ErrBaseUrlIsNotSet        = "base URL of Discourse is not set"
+	ErrNoCategories           = "no categories are found"
+	ErrTopicListIsNotFound    = "topic list is not found"
+	ErrfUnknownCategory       = "unknown category: %v"
+	ErrfUnknownParentCategory = "unknown parent category: %v"
+)
+
+const (
+	SiteUrlFormat     = "%v/site.json"
+	CategoryUrlFormat = "%v/c/%v/%v.json?page=%v"
+	QueryParamPage    = "page"
+	SlugSeparator     = "/"
+
+	// DefaultTopicsPerPage is the size of topic lists of Discourse.
+	DefaultTopicsPerPage = 30
+)
+
+// Category is a category of topics. Subcategories have a parent category.
+type Category struct {
+	Id               uint   `json:"id"`
+	Name             string `json:"name"`
+	Slug             string `json:"slug"`
+	ParentCategoryId uint   `json:"parent_category_id"`
+	Position         int    `json:"position"`
+}
+
+// site is a part of the '/site.json' response.
+type site struct {
+	Categories []*Category `json:"categories"`
+}
+
+// Categories are categories of a board in the order of the tree: each
+// category is followed by its subcategories, categories of the same parent
+// are ordered by their positions.
+type Categories struct {
+	List []*Category
+	byId map[uint]*Category
+}
+
+// categoryPage is the response of a page of a category.
+type categoryPage struct {
+	TopicList *TopicList `json:"topic_list"`
+}
+
+// TopicList is a page of topics of a category. A category lists topics of its
+// subcategories too.
+type TopicList struct {
+	// MoreTopicsUrl is a link to the next page. It is empty on the last page.
+	MoreTopicsUrl string   `json:"more_topics_url"`
+	PerPage       uint     `json:"per_page"`
+	Topics        []*Topic `json:"topics"`
+}
+
+// Topic is a topic of a topic list.
+type Topic struct {
+	Id         uint   `json:"id"`
+	Title      string `json:"title"`
+	CategoryId uint   `json:"category_id"`
+}
+
+// GetSiteUrl returns the address of the site information listing all the
+// categories visible to the user.
+func GetSiteUrl(baseUrl string) (siteUrl string, err error) {
+	baseUrl, err = normalizeBaseUrl(baseUrl)
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf(SiteUrlFormat, baseUrl), nil
+}
+
+// ParseCategories reads categories from the site information.
+func ParseCategories(data []byte) (c *Categories, err error) {
+	var s site
+	err = json.Unmarshal(data, &s)
+	if err != nil {
+		return nil, err
+	}
+	if len(s.Categories) == 0 {
+		return nil, errors.New(ErrNoCategories)
+	}
+
+	c = &Categories{
+		List: make([]*Category, 0, len(s.Categories)),
+		byId: make(map[uint]*Category, len(s.Categories)),
+	}
+
+	children := make(map[uint][]*Category)
+	for _, cat := range s.Categories {
+		c.byId[cat.Id] = cat
+		children[cat.ParentCategoryId] = append(children[cat.ParentCategoryId], cat)
+	}
+
+	for _, cat := range s.Categories {
+		if cat.ParentCategoryId == 0 {
+			continue
+		}
+
+		_, ok := c.byId[cat.ParentCategoryId]
+		if !ok {
+			return nil, fmt.Errorf(ErrfUnknownParentCategory, cat.ParentCategoryId)
+		}
+	}
+
+	// Categories in a cycle are not reachable from the top and are lost.
+	var addTree func(parentId uint)
+	addTree = func(parentId uint) {
+		list := children[parentId]
+		sort.SliceStable(list, func(i, j int) bool { return list[i].Position < list[j].Position })
+
+		for _, cat := range list {
+			c.List = append(c.List, cat)
+			addTree(cat.Id)
+		}
+	}
+	addTree(0)
+
+	return c, nil
+}
+
+// Get returns a category by its ID.
+func (c *Categories) Get(id uint) (cat *Category, err error) {
+	cat, ok := c.byId[id]
+	if !ok {
+		return nil, fmt.Errorf(ErrfUnknownCategory, id)
+	}
+
+	return cat, nil
+}
+
+// GetRoot returns the top-level category containing the category.
+func (c *Categories) GetRoot(id uint) (root *Category, err error) {
+	root, err = c.Get(id)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := 0; (root.ParentCategoryId != 0) && (i < len(c.List)); i++ {
+		root, err = c.Get(root.ParentCategoryId)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return root, nil
+}
+
+// GetPageUrl returns the address of a page of a category, i.e.
+// '/c/{slug}/{id}.json?page=N', where pages are counted from zero and slugs
+// of subcategories are prefixed with slugs of their parents.
+func (c *Categories) GetPageUrl(baseUrl string, id uint, pageIdx uint) (pageUrl string, err error) {
+	baseUrl, err = normalizeBaseUrl(baseUrl)
+	if err != nil {
+		return "", err
+	}
+
+	var cat *Category
+	cat, err = c.Get(id)
+	if err != nil {
+		return "", err
+	}
+
+	slugs := []string{url.PathEscape(cat.Slug)}
+	for i := 0; (cat.ParentCategoryId != 0) && (i < len(c.List)); i++ {
+		cat, err = c.Get(cat.ParentCategoryId)
+		if err != nil {
+			return "", err
+		}
+		slugs = append([]string{url.PathEscape(cat.Slug)}, slugs...)
+	}
+
+	return fmt.Sprintf(CategoryUrlFormat, baseUrl, strings.Join(slugs, SlugSeparator), id, pageIdx), nil
+}
+
+// ParseTopicList reads topics from a page of a category.
+func ParseTopicList(data []byte) (tl *TopicList, err error) {
+	var page categoryPage
+	err = json.Unmarshal(data, &page)
+	if err != nil {
+		return nil, err
+	}
+	if page.TopicList == nil {
+		return nil, errors.New(ErrTopicListIsNotFound)
+	}
+
+	return page.TopicList, nil
+}
+
+// GetNextPage returns the index of the next page, if there is one.
+func (tl *TopicList) GetNextPage() (pageIdx uint, ok bool, err error) {
+	if len(tl.MoreTopicsUrl) == 0 {
+		return 0, false, nil
+	}
+
+	var u *url.URL
+	u, err = url.Parse(tl.MoreTopicsUrl)
+	if err != nil {
+		return 0, false, err
+	}
+
+	pageIdx, err = number.ParseUint(u.Query().Get(QueryParamPage))
+	if err != nil {
+		return 0, false, err
+	}
+
+	return pageIdx, true, nil
+}
+
+func normalizeBaseUrl(baseUrl string) (string, error) {
+	baseUrl = strings.TrimRight(strings.TrimSpace(baseUrl), "/")
+	if len(baseUrl) == 0 {
+		return "", errors.New(ErrBaseUrlIsNotSet)
+	}
+
+	return baseUrl, nil
+}
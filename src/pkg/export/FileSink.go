@@ -0,0 +1,235 @@
+package export
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/vault-thirteen/Forum-Crawler/src/models"
+	ae "github.com/vault-thirteen/auxie/errors"
+)
+
+const (
+	ErrUnsupportedFormat = "unsupported output format: %v"
+)
+
+const (
+	DefaultFilePrefix = "topics"
+	RunIdTimeFormat   = "20060102_150405"
+	TsvSeparator      = "\t"
+	TsvNewLine        = "\n"
+)
+
+var topicColumns = []string{"forum_id", "id", "name"}
+
+// tsvEscaper escapes special symbols of a TSV field, so that each topic
+// occupies exactly one line.
+var tsvEscaper = strings.NewReplacer(`\`, `\\`, "\t", `\t`, "\n", `\n`, "\r", `\r`)
+
+// FileSink writes topics into a flat file. Forums are not exported.
+type FileSink struct {
+	format    string
+	file      *os.File
+	bw        *bufio.Writer
+	csvWriter *csv.Writer
+}
+
+// NewFileSinks creates a file sink for each format listed in settings. All
+// the files of a single run share the same name and differ by extension.
+func NewFileSinks(settings *models.OutputSettings) (sinks []*FileSink, err error) {
+	prefix := settings.FilePrefix
+	if len(prefix) == 0 {
+		prefix = DefaultFilePrefix
+	}
+	baseName := prefix + "_" + time.Now().Format(RunIdTimeFormat)
+
+	for _, format := range settings.Formats {
+		if !isFormatSupported(format) {
+			return nil, fmt.Errorf(ErrUnsupportedFormat, format)
+		}
+	}
+
+	sinks = make([]*FileSink, 0, len(settings.Formats))
+	var sink *FileSink
+	for _, format := range settings.Formats {
+		sink, err = NewFileSink(filepath.Join(settings.Folder, baseName+"."+format), format)
+		if err != nil {
+			for _, s := range sinks {
+				cerr := s.Close()
+				if cerr != nil {
+					err = ae.Combine(err, cerr)
+				}
+			}
+			return nil, err
+		}
+
+		sinks = append(sinks, sink)
+	}
+
+	return sinks, nil
+}
+
+func NewFileSink(filePath string, format string) (fs *FileSink, err error) {
+	if !isFormatSupported(format) {
+		return nil, fmt.Errorf(ErrUnsupportedFormat, format)
+	}
+
+	fs = &FileSink{
+		format: format,
+	}
+
+	fs.file, err = os.Create(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	fs.bw = bufio.NewWriter(fs.file)
+
+	err = fs.writeHeader()
+	if err != nil {
+		return nil, ae.Combine(err, fs.file.Close())
+	}
+
+	return fs, nil
+}
+
+func (fs *FileSink) writeHeader() (err error) {
+	switch fs.format {
+	case models.OutputFormat_Csv:
+		fs.csvWriter = csv.NewWriter(fs.bw)
+		return fs.csvWriter.Write(topicColumns)
+
+	case models.OutputFormat_Tsv:
+		_, err = fs.bw.WriteString(strings.Join(topicColumns, TsvSeparator) + TsvNewLine)
+		return err
+
+	default:
+		return nil
+	}
+}
+
+func (fs *FileSink) Close() (err error) {
+	err = fs.flush()
+	if err != nil {
+		return ae.Combine(err, fs.file.Close())
+	}
+
+	return fs.file.Close()
+}
+
+func (fs *FileSink) SaveForum(forum *models.Forum) (err error) {
+	return nil
+}
+
+func (fs *FileSink) SaveTopic(topic *models.Topic, isArchived bool) (err error) {
+	err = fs.writeTopic(topic)
+	if err != nil {
+		return err
+	}
+
+	return fs.flush()
+}
+
+func (fs *FileSink) SaveNewTopic(topic *models.Topic, isArchived bool) (err error) {
+	return fs.SaveTopic(topic, isArchived)
+}
+
+// SaveTopics writes topics of a forum sorted by their IDs.
+func (fs *FileSink) SaveTopics(forumId uint, topics map[uint]*models.Topic, isArchived bool) (err error) {
+	ids := make([]uint, 0, len(topics))
+	for id := range topics {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	for _, id := range ids {
+		err = fs.writeTopic(topics[id])
+		if err != nil {
+			return err
+		}
+	}
+
+	return fs.flush()
+}
+
+// UpdateTopics writes all the topics, as files have no previous state. Files
+// can not tell added topics from updated ones, so that zero counts are
+// returned.
+func (fs *FileSink) UpdateTopics(topics map[uint]*models.Topic, isArchived bool) (stats *models.TopicsUpdateStats, err error) {
+	err = fs.SaveTopics(0, topics, isArchived)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.TopicsUpdateStats{}, nil
+}
+
+// MarkMissingTopics does nothing, as files have no previous state.
+func (fs *FileSink) MarkMissingTopics(forumId uint, crawlStartedAt time.Time, isArchived bool) (count int64, err error) {
+	return 0, nil
+}
+
+func (fs *FileSink) writeTopic(topic *models.Topic) (err error) {
+	switch fs.format {
+	case models.OutputFormat_JsonLines:
+		var buf []byte
+		buf, err = json.Marshal(topic)
+		if err != nil {
+			return err
+		}
+		_, err = fs.bw.Write(append(buf, '\n'))
+		return err
+
+	case models.OutputFormat_Csv:
+		return fs.csvWriter.Write(topicRecord(topic))
+
+	case models.OutputFormat_Tsv:
+		record := topicRecord(topic)
+		for i := range record {
+			record[i] = tsvEscaper.Replace(record[i])
+		}
+		_, err = fs.bw.WriteString(strings.Join(record, TsvSeparator) + TsvNewLine)
+		return err
+
+	default:
+		return fmt.Errorf(ErrUnsupportedFormat, fs.format)
+	}
+}
+
+func (fs *FileSink) flush() (err error) {
+	if fs.csvWriter != nil {
+		fs.csvWriter.Flush()
+		err = fs.csvWriter.Error()
+		if err != nil {
+			return err
+		}
+	}
+
+	return fs.bw.Flush()
+}
+
+func topicRecord(topic *models.Topic) []string {
+	return []string{
+		strconv.FormatUint(uint64(topic.ForumId), 10),
+		strconv.FormatUint(uint64(topic.Id), 10),
+		topic.Name,
+	}
+}
+
+func isFormatSupported(format string) bool {
+	switch format {
+	case models.OutputFormat_JsonLines,
+		models.OutputFormat_Csv,
+		models.OutputFormat_Tsv:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,586 @@
+package profile
+
+import (
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/vault-thirteen/Forum-Crawler/src/pkg/selector"
+	htmldom "github.com/vault-thirteen/auxie/HTML-DOM"
+	"github.com/vault-thirteen/auxie/number"
+	"golang.org/x/net/html"
+)
+
+const (
+	ErrfUnknownProfile      = "unknown site profile: %v"
+	ErrfProfileField        = "site profile %v: %v: %w"
+	ErrFieldIsNotSet        = "field is not set"
+	ErrNoTopicsAreFound     = "no topics are found"
+	ErrNoPageNumbers        = "no page numbers"
+	ErrfValueIsNotFound     = "%v is not found in a topic row"
+	ErrfValueMismatch       = "%v does not match the pattern: %v"
+	ErrfTopicIdMismatch     = "topic ID mismatch: %v vs %v"
+	ErrPatternHasManyGroups = "pattern has more than one group"
+)
+
+const (
+	DefaultProfileName = "torrentpier"
+	ProfileFileExt     = ".json"
+	BuiltInFolder      = "profiles"
+	TagWbr             = `<wbr/>`
+)
+
+//go:embed profiles/*.json
+var builtInProfiles embed.FS
+
+// Profile describes the layout of forum pages of a site. Elements of pages
+// are found by CSS selectors, values of elements are read either from their
+// attributes or from their contents and may be extracted by regular
+// expressions.
+type Profile struct {
+	Name       string             `json:"name"`
+	Topics     *TopicsProfile     `json:"topics"`
+	Pagination *PaginationProfile `json:"pagination"`
+	Index      *IndexProfile      `json:"index"`
+}
+
+// TopicsProfile describes the list of topics of a forum page. Rows of topics
+// are searched for in the whole page, values are searched for inside a row.
+type TopicsProfile struct {
+	// Rows selects elements containing a single topic each.
+	Rows string `json:"rows"`
+
+	// Exclude selects rows which are skipped, e.g. announcements shown in
+	// all forums or links to topics moved to other forums. A row is skipped
+	// when either the row itself or any element inside it matches.
+	Exclude string `json:"exclude"`
+
+	// Id is the ID of a topic. Rows without the ID are skipped, e.g. headers
+	// and separators.
+	Id *ValueProfile `json:"id"`
+
+	// Name is the name of a topic.
+	Name *ValueProfile `json:"name"`
+
+	// Checks are values which must be equal to the ID of a topic, e.g. the
+	// ID in the link to the topic. They protect from reading a name of some
+	// other topic.
+	Checks []*ValueProfile `json:"checks"`
+
+	// AllowEmptyPage allows pages without topics. Otherwise, a page without
+	// topics is treated as a broken layout.
+	AllowEmptyPage bool `json:"allowEmptyPage"`
+
+	rows    *selector.Selector
+	exclude *selector.Selector
+}
+
+// PaginationProfile describes page numbers of a forum page.
+type PaginationProfile struct {
+	// Pages selects elements with page numbers. The greatest number is the
+	// count of pages, elements which are not numbers are ignored.
+	Pages string `json:"pages"`
+
+	// AllowMissing allows pages without page numbers, i.e. such a forum has
+	// a single page. Otherwise, missing page numbers are treated as a broken
+	// layout.
+	AllowMissing bool `json:"allowMissing"`
+
+	pages *selector.Selector
+}
+
+// IndexProfile describes links to categories and forums of the forum index
+// page. Values are read from the link itself. The section is optional, it is
+// needed only to discover forums.
+type IndexProfile struct {
+	// Categories selects links to categories.
+	Categories string `json:"categories"`
+
+	// CategoryId is the ID of a category.
+	CategoryId *ValueProfile `json:"categoryId"`
+
+	// Forums selects links to forums.
+	Forums string `json:"forums"`
+
+	// ForumId is the ID of a forum.
+	ForumId *ValueProfile `json:"forumId"`
+
+	// SubForums selects lists of sub-forums. A link is a link to a
+	// sub-forum when either the link itself or any of its ancestors matches.
+	SubForums string `json:"subForums"`
+
+	categories *selector.Selector
+	forums     *selector.Selector
+	subForums  *selector.Selector
+}
+
+// ValueProfile describes a value found in a topic row.
+type ValueProfile struct {
+	// Selector selects the element inside the row. Empty selector means the
+	// row itself. The first matching element is used.
+	Selector string `json:"selector"`
+
+	// Attribute is the attribute holding the value. Empty attribute means
+	// the contents of the element, i.e. its inner HTML without '<wbr/>' tags
+	// and with HTML entities unescaped.
+	Attribute string `json:"attribute"`
+
+	// Pattern is an optional regular expression extracting the value. When
+	// it has a group, the value is the group, otherwise it is the whole
+	// match.
+	Pattern string `json:"pattern"`
+
+	selector *selector.Selector
+	pattern  *regexp.Regexp
+}
+
+// Topic is a topic found on a forum page.
+type Topic struct {
+	Id   uint
+	Name string
+}
+
+// IndexLink is a link to a category or a forum found on the forum index page.
+type IndexLink struct {
+	IsCategory bool
+	Id         uint
+	Name       string
+	IsSubForum bool
+}
+
+// Load reads a site profile by its name. A profile is searched for as the
+// '<name>.json' file in the folder and then among built-in profiles. Empty
+// name means the default profile.
+func Load(name string, folder string) (p *Profile, err error) {
+	if len(name) == 0 {
+		name = DefaultProfileName
+	}
+
+	var buf []byte
+	if len(folder) > 0 {
+		buf, err = os.ReadFile(filepath.Join(folder, name+ProfileFileExt))
+		if (err != nil) && !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+	}
+
+	if buf == nil {
+		buf, err = builtInProfiles.ReadFile(BuiltInFolder + "/" + name + ProfileFileExt)
+		if err != nil {
+			return nil, fmt.Errorf(ErrfUnknownProfile, name)
+		}
+	}
+
+	p = &Profile{}
+	err = json.Unmarshal(buf, p)
+	if err != nil {
+		return nil, err
+	}
+	if len(p.Name) == 0 {
+		p.Name = name
+	}
+
+	err = p.compile()
+	if err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+// compile checks the profile and compiles its selectors and patterns.
+func (p *Profile) compile() (err error) {
+	if p.Topics == nil {
+		return p.fieldError("topics", errors.New(ErrFieldIsNotSet))
+	}
+	if p.Pagination == nil {
+		return p.fieldError("pagination", errors.New(ErrFieldIsNotSet))
+	}
+	if p.Topics.Id == nil {
+		return p.fieldError("topics.id", errors.New(ErrFieldIsNotSet))
+	}
+	if p.Topics.Name == nil {
+		return p.fieldError("topics.name", errors.New(ErrFieldIsNotSet))
+	}
+
+	p.Topics.rows, err = compileSelector(p.Topics.Rows)
+	if err != nil {
+		return p.fieldError("topics.rows", err)
+	}
+
+	if len(p.Topics.Exclude) > 0 {
+		p.Topics.exclude, err = selector.Compile(p.Topics.Exclude)
+		if err != nil {
+			return p.fieldError("topics.exclude", err)
+		}
+	}
+
+	err = p.Topics.Id.compile()
+	if err != nil {
+		return p.fieldError("topics.id", err)
+	}
+
+	err = p.Topics.Name.compile()
+	if err != nil {
+		return p.fieldError("topics.name", err)
+	}
+
+	for i, check := range p.Topics.Checks {
+		err = check.compile()
+		if err != nil {
+			return p.fieldError(fmt.Sprintf("topics.checks[%v]", i), err)
+		}
+	}
+
+	p.Pagination.pages, err = compileSelector(p.Pagination.Pages)
+	if err != nil {
+		return p.fieldError("pagination.pages", err)
+	}
+
+	if p.Index != nil {
+		err = p.compileIndex()
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (p *Profile) compileIndex() (err error) {
+	if p.Index.CategoryId == nil {
+		return p.fieldError("index.categoryId", errors.New(ErrFieldIsNotSet))
+	}
+	if p.Index.ForumId == nil {
+		return p.fieldError("index.forumId", errors.New(ErrFieldIsNotSet))
+	}
+
+	p.Index.categories, err = compileSelector(p.Index.Categories)
+	if err != nil {
+		return p.fieldError("index.categories", err)
+	}
+
+	err = p.Index.CategoryId.compile()
+	if err != nil {
+		return p.fieldError("index.categoryId", err)
+	}
+
+	p.Index.forums, err = compileSelector(p.Index.Forums)
+	if err != nil {
+		return p.fieldError("index.forums", err)
+	}
+
+	err = p.Index.ForumId.compile()
+	if err != nil {
+		return p.fieldError("index.forumId", err)
+	}
+
+	if len(p.Index.SubForums) > 0 {
+		p.Index.subForums, err = selector.Compile(p.Index.SubForums)
+		if err != nil {
+			return p.fieldError("index.subForums", err)
+		}
+	}
+
+	return nil
+}
+
+func (p *Profile) fieldError(field string, err error) error {
+	return fmt.Errorf(ErrfProfileField, p.Name, field, err)
+}
+
+func (v *ValueProfile) compile() (err error) {
+	if len(v.Selector) > 0 {
+		v.selector, err = selector.Compile(v.Selector)
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(v.Pattern) > 0 {
+		v.pattern, err = regexp.Compile(v.Pattern)
+		if err != nil {
+			return err
+		}
+		if v.pattern.NumSubexp() > 1 {
+			return errors.New(ErrPatternHasManyGroups)
+		}
+	}
+
+	return nil
+}
+
+func compileSelector(text string) (s *selector.Selector, err error) {
+	if len(text) == 0 {
+		return nil, errors.New(ErrFieldIsNotSet)
+	}
+
+	return selector.Compile(text)
+}
+
+// FindTopics searches for topics in a forum page.
+func (p *Profile) FindTopics(doc *html.Node) (topics []*Topic, err error) {
+	topics = make([]*Topic, 0)
+	for _, row := range p.Topics.rows.Select(doc) {
+		if p.Topics.isExcluded(row) {
+			continue
+		}
+
+		var topic *Topic
+		topic, err = p.findTopic(row)
+		if err != nil {
+			return nil, err
+		}
+		if topic == nil {
+			continue
+		}
+
+		topics = append(topics, topic)
+	}
+
+	if (len(topics) == 0) && !p.Topics.AllowEmptyPage {
+		return nil, errors.New(ErrNoTopicsAreFound)
+	}
+
+	return topics, nil
+}
+
+// isExcluded checks whether the row is skipped.
+func (tp *TopicsProfile) isExcluded(row *html.Node) bool {
+	if tp.exclude == nil {
+		return false
+	}
+
+	return tp.exclude.Match(row) || (tp.exclude.SelectFirst(row) != nil)
+}
+
+// findTopic reads a topic from its row. Nil topic is returned for rows
+// without the topic ID.
+func (p *Profile) findTopic(row *html.Node) (topic *Topic, err error) {
+	idStr, ok, err := p.Topics.Id.find(row, "topic ID")
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, nil
+	}
+
+	topic = &Topic{}
+	topic.Id, err = number.ParseUint(idStr)
+	if err != nil {
+		return nil, err
+	}
+
+	topic.Name, ok, err = p.Topics.Name.find(row, "topic name")
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, fmt.Errorf(ErrfValueIsNotFound, "topic name")
+	}
+
+	// Integrity Check.
+	for _, check := range p.Topics.Checks {
+		var checkStr string
+		checkStr, ok, err = check.find(row, "topic ID")
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return nil, fmt.Errorf(ErrfValueIsNotFound, "topic ID "+check.describe())
+		}
+
+		var id uint
+		id, err = number.ParseUint(checkStr)
+		if err != nil {
+			return nil, err
+		}
+		if id != topic.Id {
+			return nil, fmt.Errorf(ErrfTopicIdMismatch, topic.Id, id)
+		}
+	}
+
+	return topic, nil
+}
+
+// FindPageCount searches for the count of pages in a forum page.
+func (p *Profile) FindPageCount(doc *html.Node) (pageCount uint, err error) {
+	for _, n := range p.Pagination.pages.Select(doc) {
+		var pageNumber uint
+		pageNumber, err = number.ParseUint(getNodeText(n))
+		if err != nil {
+			continue
+		}
+
+		pageCount = max(pageCount, pageNumber)
+	}
+
+	if pageCount == 0 {
+		if p.Pagination.AllowMissing {
+			return 1, nil
+		}
+
+		return 0, errors.New(ErrNoPageNumbers)
+	}
+
+	return pageCount, nil
+}
+
+// FindIndexLinks searches for links to categories and forums in the forum
+// index page in the order of the document. Links without an ID are skipped.
+func (p *Profile) FindIndexLinks(doc *html.Node) (links []*IndexLink, err error) {
+	if p.Index == nil {
+		return nil, p.fieldError("index", errors.New(ErrFieldIsNotSet))
+	}
+
+	links = make([]*IndexLink, 0)
+	walkNodes(doc, func(n *html.Node) bool {
+		var link *IndexLink
+		link, err = p.Index.findLink(n)
+		if err != nil {
+			return false
+		}
+		if link != nil {
+			links = append(links, link)
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return links, nil
+}
+
+// findLink reads a link to a category or a forum. Nil link is returned for
+// other nodes.
+func (ip *IndexProfile) findLink(n *html.Node) (link *IndexLink, err error) {
+	if n.Type != html.ElementNode {
+		return nil, nil
+	}
+
+	link = &IndexLink{}
+	var id *ValueProfile
+	var name string
+	if ip.categories.Match(n) {
+		link.IsCategory, id, name = true, ip.CategoryId, "category ID"
+	} else if ip.forums.Match(n) {
+		id, name = ip.ForumId, "forum ID"
+	} else {
+		return nil, nil
+	}
+
+	idStr, ok, err := id.find(n, name)
+	if (err != nil) || !ok {
+		return nil, err
+	}
+
+	link.Id, err = number.ParseUint(idStr)
+	if err != nil {
+		return nil, err
+	}
+	if link.Id == 0 {
+		return nil, nil
+	}
+
+	link.Name = getNodeText(n)
+	link.IsSubForum = !link.IsCategory && ip.isInSubForumList(n)
+
+	return link, nil
+}
+
+// isInSubForumList checks whether the link or any of its ancestors is a list
+// of sub-forums.
+func (ip *IndexProfile) isInSubForumList(n *html.Node) bool {
+	if ip.subForums == nil {
+		return false
+	}
+
+	for ; n != nil; n = n.Parent {
+		if ip.subForums.Match(n) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// find reads the value in a row. A value which is missing, i.e. its element
+// or attribute is not found, is not an error. A value not matching the
+// pattern is an error.
+func (v *ValueProfile) find(row *html.Node, name string) (value string, ok bool, err error) {
+	n := row
+	if v.selector != nil {
+		n = v.selector.SelectFirst(row)
+		if n == nil {
+			return "", false, nil
+		}
+	}
+
+	if len(v.Attribute) > 0 {
+		value, ok = htmldom.GetNodeAttributeValue(n, v.Attribute)
+		if !ok {
+			return "", false, nil
+		}
+	} else {
+		value, err = htmldom.GetInnerHtml(n)
+		if err != nil {
+			return "", false, err
+		}
+		value = clearName(value)
+	}
+
+	if v.pattern == nil {
+		return value, true, nil
+	}
+
+	match := v.pattern.FindStringSubmatch(value)
+	if match == nil {
+		return "", false, fmt.Errorf(ErrfValueMismatch, name, value)
+	}
+
+	return match[len(match)-1], true, nil
+}
+
+// describe returns a short description of the value for error messages.
+func (v *ValueProfile) describe() string {
+	return fmt.Sprintf("(%v %v)", v.Selector, v.Attribute)
+}
+
+// getNodeText returns the text of the node and all its descendants.
+func getNodeText(n *html.Node) string {
+	var sb strings.Builder
+	walkNodes(n, func(n *html.Node) bool {
+		if n.Type == html.TextNode {
+			sb.WriteString(n.Data)
+		}
+		return true
+	})
+
+	return strings.TrimSpace(sb.String())
+}
+
+// walkNodes calls the function for the node and all its descendants in the
+// order of the document until the function returns false.
+func walkNodes(n *html.Node, fn func(n *html.Node) bool) bool {
+	if !fn(n) {
+		return false
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if !walkNodes(c, fn) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func clearName(dirtyName string) (cleanName string) {
+	return html.UnescapeString(strings.ReplaceAll(dirtyName, TagWbr, ""))
+}
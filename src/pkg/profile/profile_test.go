@@ -0,0 +1,280 @@
+package profile
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/html"
+)
+
+const (
+	TestDataFolder         = "testdata"
+	ProfileNamePhpBB3      = "phpbb3"
+	ProfileNameTorrentPier = "torrentpier"
+	FixturePhpBB30Page     = "phpbb30_pages.html"
+	FixtureTorrentPierPage = "torrentpier_pages.html"
+	TorrentPierTopicAnchor = `<a id="tt-6099877"`
+)
+
+func Test_FindTopics_TorrentPier(t *testing.T) {
+	p := mustLoadProfile(t, ProfileNameTorrentPier)
+
+	// Test #1. Several pages. Rows of sub-forums and separators are skipped,
+	// names are cleared of '<wbr>' tags and HTML entities. The link to the
+	// next page is not a page number.
+	topics, pageCount := mustFindTopics(t, p, FixtureTorrentPierPage)
+	mustBeEqual(t, topics, []Topic{
+		{Id: 4200010, Name: "[Прилепленная] Правила раздела"},
+		{Id: 6100234, Name: `Debian 12.5 "Bookworm" [amd64] [3xDVD]`},
+		{Id: 6099877, Name: "Arch Linux 2024.02.01 & archinstall [x86_64]"},
+	})
+	mustBeEqual(t, pageCount, uint(12))
+
+	// Test #2. Single page.
+	_, pageCount = mustFindTopics(t, p, "torrentpier_single.html")
+	mustBeEqual(t, pageCount, uint(1))
+
+	// Test #3. The link of a topic belongs to another topic.
+	doc := mustParseFile(t, "torrentpier_mismatch.html")
+	_, err := p.FindTopics(doc)
+	mustBeError(t, err, "topic ID mismatch: 6099877 vs 6099878")
+
+	// Test #4. The link of a topic has no 'tt-' prefix.
+	doc = mustParseText(t, strings.Replace(mustReadFile(t, FixtureTorrentPierPage), TorrentPierTopicAnchor, `<a id="link-6099877"`, 1))
+	_, err = p.FindTopics(doc)
+	mustBeError(t, err, "topic ID does not match the pattern: link-6099877")
+
+	// Test #5. Pages without page numbers are a broken layout.
+	doc = mustParseText(t, strings.ReplaceAll(mustReadFile(t, FixtureTorrentPierPage), `<p style`, `<div style`))
+	_, err = p.FindPageCount(doc)
+	mustBeError(t, err, ErrNoPageNumbers)
+}
+
+func Test_FindTopics_PhpBB30(t *testing.T) {
+	p := mustLoadProfile(t, ProfileNamePhpBB3)
+
+	// Test #1. Several pages. The global announcement and the link left in
+	// place of a moved topic are skipped.
+	topics, pageCount := mustFindTopics(t, p, FixturePhpBB30Page)
+	mustBeEqual(t, topics, []Topic{
+		{Id: 40, Name: "Read before posting"},
+		{Id: 51, Name: "Frequently asked questions"},
+		{Id: 1207, Name: `Q & A: "Which backup tool?"`},
+		{Id: 1188, Name: "Weekend meetup"},
+	})
+	mustBeEqual(t, pageCount, uint(7))
+
+	// Test #2. Single page.
+	topics, pageCount = mustFindTopics(t, p, "phpbb30_single.html")
+	mustBeEqual(t, topics, []Topic{
+		{Id: 1199, Name: "Old thread about printers"},
+		{Id: 930, Name: "RAM prices"},
+		{Id: 12, Name: "Show your desk"},
+	})
+	mustBeEqual(t, pageCount, uint(1))
+
+	// Test #3. Empty forum.
+	topics, pageCount = mustFindTopics(t, p, "phpbb30_empty.html")
+	mustBeEqual(t, topics, []Topic{})
+	mustBeEqual(t, pageCount, uint(1))
+
+	// Test #4. Sub-forums are listed above topics.
+	topics, pageCount = mustFindTopics(t, p, "phpbb30_subforums.html")
+	mustBeEqual(t, topics, []Topic{
+		{Id: 1300, Name: "Text editors compared"},
+		{Id: 1150, Name: "Backup scripts"},
+	})
+	mustBeEqual(t, pageCount, uint(2))
+}
+
+func Test_FindTopics_PhpBB31(t *testing.T) {
+	p := mustLoadProfile(t, ProfileNamePhpBB3)
+
+	// Test #1. Several pages. The global announcement and the link left in
+	// place of a moved topic are skipped, pages of topics are not counted.
+	topics, pageCount := mustFindTopics(t, p, "phpbb31_pages.html")
+	mustBeEqual(t, topics, []Topic{
+		{Id: 40, Name: "Read before posting"},
+		{Id: 51, Name: "Frequently asked questions"},
+		{Id: 1207, Name: `Q & A: "Which backup tool?"`},
+		{Id: 1188, Name: "Weekend meetup"},
+	})
+	mustBeEqual(t, pageCount, uint(13))
+
+	// Test #2. Single page. The topic has more pages than the forum.
+	topics, pageCount = mustFindTopics(t, p, "phpbb31_single.html")
+	mustBeEqual(t, topics, []Topic{
+		{Id: 1199, Name: "Old thread about printers"},
+		{Id: 930, Name: "RAM prices"},
+		{Id: 12, Name: "Show your desk"},
+	})
+	mustBeEqual(t, pageCount, uint(1))
+
+	// Test #3. Empty forum.
+	topics, pageCount = mustFindTopics(t, p, "phpbb31_empty.html")
+	mustBeEqual(t, topics, []Topic{})
+	mustBeEqual(t, pageCount, uint(1))
+
+	// Test #4. Sub-forums are listed above topics.
+	topics, pageCount = mustFindTopics(t, p, "phpbb31_subforums.html")
+	mustBeEqual(t, topics, []Topic{
+		{Id: 1300, Name: "Text editors compared"},
+		{Id: 1150, Name: "Backup scripts"},
+	})
+	mustBeEqual(t, pageCount, uint(2))
+}
+
+func Test_FindTopics_Exclude(t *testing.T) {
+	p := mustLoadProfile(t, ProfileNamePhpBB3)
+
+	// Test #1. Without exclusion, global announcements and moved topics are
+	// read as usual topics.
+	p.Topics.exclude = nil
+	topics, _ := mustFindTopics(t, p, FixturePhpBB30Page)
+	mustBeEqual(t, topics, []Topic{
+		{Id: 3, Name: "Board rules"},
+		{Id: 40, Name: "Read before posting"},
+		{Id: 51, Name: "Frequently asked questions"},
+		{Id: 1207, Name: `Q & A: "Which backup tool?"`},
+		{Id: 1199, Name: "Old thread about printers"},
+		{Id: 1188, Name: "Weekend meetup"},
+	})
+}
+
+func Test_FindIndexLinks(t *testing.T) {
+	// Test #1. TorrentPier. Links to the index page without a category, to
+	// the tracker and repeated links to forums are read as they are.
+	links := mustFindIndexLinks(t, mustLoadProfile(t, ProfileNameTorrentPier), "torrentpier_index.html")
+	mustBeEqual(t, links, []IndexLink{
+		{IsCategory: true, Id: 7, Name: "Operating systems"},
+		{Id: 1379, Name: "Linux"},
+		{Id: 1380, Name: "Distributions", IsSubForum: true},
+		{Id: 1381, Name: "Software & tools", IsSubForum: true},
+		{Id: 1379, Name: "Linux"},
+		{Id: 1400, Name: "BSD"},
+		{IsCategory: true, Id: 9, Name: "Archive"},
+		{Id: 1001, Name: "Archived topics"},
+	})
+
+	// Test #2. phpBB. Categories are forums in the header of a list.
+	links = mustFindIndexLinks(t, mustLoadProfile(t, ProfileNamePhpBB3), "phpbb31_index.html")
+	mustBeEqual(t, links, []IndexLink{
+		{IsCategory: true, Id: 1, Name: "Main"},
+		{Id: 2, Name: "Software"},
+		{Id: 5, Name: "Editors", IsSubForum: true},
+		{Id: 6, Name: "Backup", IsSubForum: true},
+		{Id: 4, Name: "Hardware"},
+		{IsCategory: true, Id: 3, Name: "Off-topic"},
+		{Id: 7, Name: "Chat"},
+	})
+
+	// Test #3. A profile without the index section.
+	p := mustLoadProfile(t, ProfileNameTorrentPier)
+	p.Index = nil
+	_, err := p.FindIndexLinks(mustParseFile(t, "torrentpier_index.html"))
+	mustBeError(t, err, "site profile torrentpier: index: field is not set")
+}
+
+func mustBeEqual(t *testing.T, actual any, expected any) {
+	t.Helper()
+
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("values are not equal:\nactual:   %#v\nexpected: %#v", actual, expected)
+	}
+}
+
+func mustLoadProfile(t *testing.T, name string) (p *Profile) {
+	t.Helper()
+
+	p, err := Load(name, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return p
+}
+
+// mustFindTopics reads topics and the count of pages from a saved page.
+func mustFindTopics(t *testing.T, p *Profile, fileName string) (topics []Topic, pageCount uint) {
+	t.Helper()
+
+	doc := mustParseFile(t, fileName)
+
+	found, err := p.FindTopics(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	topics = make([]Topic, 0, len(found))
+	for _, topic := range found {
+		topics = append(topics, *topic)
+	}
+
+	pageCount, err = p.FindPageCount(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return topics, pageCount
+}
+
+// mustFindIndexLinks reads links to categories and forums from a saved index
+// page.
+func mustFindIndexLinks(t *testing.T, p *Profile, fileName string) (links []IndexLink) {
+	t.Helper()
+
+	found, err := p.FindIndexLinks(mustParseFile(t, fileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	links = make([]IndexLink, 0, len(found))
+	for _, link := range found {
+		links = append(links, *link)
+	}
+
+	return links
+}
+
+func mustBeError(t *testing.T, err error, expected string) {
+	t.Helper()
+
+	if err == nil {
+		t.Errorf("error is expected: %v", expected)
+		return
+	}
+	if err.Error() != expected {
+		t.Errorf("errors are not equal:\nactual:   %v\nexpected: %v", err, expected)
+	}
+}
+
+func mustReadFile(t *testing.T, fileName string) (text string) {
+	t.Helper()
+
+	buf, err := os.ReadFile(filepath.Join(TestDataFolder, fileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return string(buf)
+}
+
+func mustParseFile(t *testing.T, fileName string) (doc *html.Node) {
+	t.Helper()
+
+	return mustParseText(t, mustReadFile(t, fileName))
+}
+
+func mustParseText(t *testing.T, text string) (doc *html.Node) {
+	t.Helper()
+
+	doc, err := html.Parse(strings.NewReader(text))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return doc
+}
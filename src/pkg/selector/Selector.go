@@ -0,0 +1,669 @@
+package selector
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	htmldom "github.com/vault-thirteen/auxie/HTML-DOM"
+	"golang.org/x/net/html"
+)
+
+const (
+	ErrSelectorIsEmpty      = "selector is empty"
+	ErrfUnexpectedSymbol    = "unexpected symbol at position %v of selector: %v"
+	ErrfUnexpectedEnd       = "unexpected end of selector: %v"
+	ErrfUnsupportedSelector = "unsupported selector at position %v: %v"
+	ErrfBadNthExpression    = "bad expression of ':nth-child' selector: %v"
+)
+
+const (
+	CombinatorDescendant = ' '
+	CombinatorChild      = '>'
+)
+
+const (
+	AttributeOperatorExists      = ""
+	AttributeOperatorEquals      = "="
+	AttributeOperatorIncludes    = "~="
+	AttributeOperatorDashMatch   = "|="
+	AttributeOperatorPrefixMatch = "^="
+	AttributeOperatorSuffixMatch = "$="
+	AttributeOperatorSubstring   = "*="
+)
+
+const (
+	PseudoClassFirstChild   = "first-child"
+	PseudoClassLastChild    = "last-child"
+	PseudoClassNthChild     = "nth-child"
+	PseudoClassNthLastChild = "nth-last-child"
+	NthOdd                  = "odd"
+	NthEven                 = "even"
+)
+
+// Selector is a compiled CSS selector matching element nodes of an HTML
+// document. Supported are type selectors, the universal selector, ID and
+// class selectors, attribute selectors with all the operators ('=', '~=',
+// '|=', '^=', '$=', '*=') and the 'i' flag, ':first-child', ':last-child',
+// ':nth-child()' and ':nth-last-child()' pseudo-classes, descendant and child
+// combinators and groups of selectors, e.g.
+// 'table.forumline tr[id^=tr-] .torTopic a[id], div#pages > a:nth-child(2n+1)'.
+//
+// As in browsers, ancestors of a matched element may be outside the root node
+// of a search.
+type Selector struct {
+	text   string
+	groups []*complexSelector
+}
+
+// complexSelector is a chain of compound selectors joined by combinators. The
+// last compound selector matches the element itself.
+type complexSelector struct {
+	compounds []*compoundSelector
+}
+
+// compoundSelector is a sequence of simple selectors matching a single
+// element. Combinator joins the compound selector with the previous one.
+type compoundSelector struct {
+	combinator    byte
+	tag           string
+	id            string
+	classes       []string
+	attributes    []*attributeSelector
+	pseudoClasses []*nthSelector
+}
+
+// attributeSelector checks an attribute of an element.
+type attributeSelector struct {
+	name       string
+	operator   string
+	value      string
+	ignoreCase bool
+}
+
+// nthSelector checks the position of an element among its sibling elements.
+// The position, counted from 1, must be 'a*n+b' for some non-negative 'n'.
+type nthSelector struct {
+	a       int
+	b       int
+	fromEnd bool
+}
+
+// Compile parses a selector.
+func Compile(text string) (s *Selector, err error) {
+	p := &parser{text: text}
+
+	s = &Selector{text: text}
+	s.groups, err = p.parseGroups()
+	if err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// MustCompile parses a selector and panics when the selector is not valid.
+func MustCompile(text string) (s *Selector) {
+	s, err := Compile(text)
+	if err != nil {
+		panic(err)
+	}
+
+	return s
+}
+
+// String returns the text of the selector.
+func (s *Selector) String() string {
+	return s.text
+}
+
+// Select returns all the descendants of the root node matching the selector
+// in the order of the document.
+func (s *Selector) Select(root *html.Node) (nodes []*html.Node) {
+	nodes = make([]*html.Node, 0)
+	if root == nil {
+		return nodes
+	}
+
+	walkDescendants(root, func(n *html.Node) bool {
+		if s.Match(n) {
+			nodes = append(nodes, n)
+		}
+		return true
+	})
+
+	return nodes
+}
+
+// SelectFirst returns the first descendant of the root node matching the
+// selector, or nil.
+func (s *Selector) SelectFirst(root *html.Node) (node *html.Node) {
+	if root == nil {
+		return nil
+	}
+
+	walkDescendants(root, func(n *html.Node) bool {
+		if s.Match(n) {
+			node = n
+			return false
+		}
+		return true
+	})
+
+	return node
+}
+
+// Match checks whether the node matches the selector.
+func (s *Selector) Match(n *html.Node) bool {
+	if n.Type != html.ElementNode {
+		return false
+	}
+
+	for _, g := range s.groups {
+		if g.matchAt(len(g.compounds)-1, n) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// matchAt checks compound selectors from the one with the index to the first
+// one. The previous compound selector matches either the parent of the
+// element or any of its ancestors depending on the combinator.
+func (cs *complexSelector) matchAt(i int, n *html.Node) bool {
+	c := cs.compounds[i]
+	if !c.match(n) {
+		return false
+	}
+	if i == 0 {
+		return true
+	}
+
+	if c.combinator == CombinatorChild {
+		p := parentElement(n)
+		return (p != nil) && cs.matchAt(i-1, p)
+	}
+
+	for p := parentElement(n); p != nil; p = parentElement(p) {
+		if cs.matchAt(i-1, p) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (c *compoundSelector) match(n *html.Node) bool {
+	if (n.Type != html.ElementNode) || ((len(c.tag) > 0) && (n.Data != c.tag)) {
+		return false
+	}
+
+	if len(c.id) > 0 {
+		id, ok := htmldom.GetNodeAttributeValue(n, htmldom.AttributeId)
+		if !ok || (id != c.id) {
+			return false
+		}
+	}
+
+	if len(c.classes) > 0 {
+		classAttr, _ := htmldom.GetNodeAttributeValue(n, htmldom.AttributeClass)
+		classes := strings.Fields(classAttr)
+		for _, class := range c.classes {
+			if !contains(classes, class) {
+				return false
+			}
+		}
+	}
+
+	for _, attr := range c.attributes {
+		if !attr.match(n) {
+			return false
+		}
+	}
+
+	for _, pc := range c.pseudoClasses {
+		if !pc.match(n) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (as *attributeSelector) match(n *html.Node) bool {
+	value, ok := htmldom.GetNodeAttributeValue(n, as.name)
+	if !ok {
+		return false
+	}
+
+	expected := as.value
+	if as.ignoreCase {
+		value, expected = strings.ToLower(value), strings.ToLower(expected)
+	}
+
+	switch as.operator {
+	case AttributeOperatorExists:
+		return true
+	case AttributeOperatorEquals:
+		return value == expected
+	case AttributeOperatorIncludes:
+		return contains(strings.Fields(value), expected)
+	case AttributeOperatorDashMatch:
+		return (value == expected) || strings.HasPrefix(value, expected+"-")
+	case AttributeOperatorPrefixMatch:
+		return (len(expected) > 0) && strings.HasPrefix(value, expected)
+	case AttributeOperatorSuffixMatch:
+		return (len(expected) > 0) && strings.HasSuffix(value, expected)
+	case AttributeOperatorSubstring:
+		return (len(expected) > 0) && strings.Contains(value, expected)
+	default:
+		return false
+	}
+}
+
+func (ns *nthSelector) match(n *html.Node) bool {
+	pos := 1
+	for s := nextSibling(n, ns.fromEnd); s != nil; s = nextSibling(s, ns.fromEnd) {
+		if s.Type == html.ElementNode {
+			pos++
+		}
+	}
+
+	if ns.a == 0 {
+		return pos == ns.b
+	}
+
+	d := pos - ns.b
+	return (d%ns.a == 0) && (d/ns.a >= 0)
+}
+
+// nextSibling returns the previous sibling of the node when the position is
+// counted from the start, or the next sibling when it is counted from the end.
+func nextSibling(n *html.Node, fromEnd bool) *html.Node {
+	if fromEnd {
+		return n.NextSibling
+	}
+
+	return n.PrevSibling
+}
+
+// parser reads a selector symbol by symbol.
+type parser struct {
+	text string
+	pos  int
+}
+
+func (p *parser) parseGroups() (groups []*complexSelector, err error) {
+	if len(strings.TrimSpace(p.text)) == 0 {
+		return nil, errors.New(ErrSelectorIsEmpty)
+	}
+
+	groups = make([]*complexSelector, 0, 1)
+	for {
+		var cs *complexSelector
+		cs, err = p.parseComplex()
+		if err != nil {
+			return nil, err
+		}
+		groups = append(groups, cs)
+
+		p.skipSpaces()
+		if p.isEnd() {
+			return groups, nil
+		}
+		if p.peek() != ',' {
+			return nil, p.unexpected()
+		}
+		p.pos++
+	}
+}
+
+func (p *parser) parseComplex() (cs *complexSelector, err error) {
+	cs = &complexSelector{}
+	var combinator byte = CombinatorDescendant
+	for {
+		p.skipSpaces()
+
+		var c *compoundSelector
+		c, err = p.parseCompound()
+		if err != nil {
+			return nil, err
+		}
+		c.combinator = combinator
+		cs.compounds = append(cs.compounds, c)
+
+		hasSpace := p.skipSpaces()
+		if p.isEnd() || (p.peek() == ',') {
+			return cs, nil
+		}
+
+		switch p.peek() {
+		case CombinatorChild:
+			p.pos++
+			combinator = CombinatorChild
+		case '+', '~':
+			return nil, fmt.Errorf(ErrfUnsupportedSelector, p.pos, p.text)
+		default:
+			if !hasSpace {
+				return nil, p.unexpected()
+			}
+			combinator = CombinatorDescendant
+		}
+	}
+}
+
+func (p *parser) parseCompound() (c *compoundSelector, err error) {
+	c = &compoundSelector{}
+	start := p.pos
+
+	if !p.isEnd() && (p.peek() == '*') {
+		p.pos++
+	} else if !p.isEnd() && isNameSymbol(p.peek()) {
+		c.tag = strings.ToLower(p.readName())
+	}
+
+	for !p.isEnd() {
+		switch p.peek() {
+		case '#':
+			p.pos++
+			c.id, err = p.readRequiredName()
+		case '.':
+			p.pos++
+			var class string
+			class, err = p.readRequiredName()
+			c.classes = append(c.classes, class)
+		case '[':
+			p.pos++
+			var attr *attributeSelector
+			attr, err = p.parseAttribute()
+			c.attributes = append(c.attributes, attr)
+		case ':':
+			p.pos++
+			var pc *nthSelector
+			pc, err = p.parsePseudoClass()
+			c.pseudoClasses = append(c.pseudoClasses, pc)
+		default:
+			if p.pos == start {
+				return nil, p.unexpected()
+			}
+			return c, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if p.pos == start {
+		return nil, fmt.Errorf(ErrfUnexpectedEnd, p.text)
+	}
+
+	return c, nil
+}
+
+// parseAttribute reads an attribute selector after the opening bracket.
+func (p *parser) parseAttribute() (as *attributeSelector, err error) {
+	as = &attributeSelector{}
+
+	p.skipSpaces()
+	as.name, err = p.readRequiredName()
+	if err != nil {
+		return nil, err
+	}
+	as.name = strings.ToLower(as.name)
+
+	p.skipSpaces()
+	if p.isEnd() {
+		return nil, fmt.Errorf(ErrfUnexpectedEnd, p.text)
+	}
+	if p.peek() == ']' {
+		p.pos++
+		return as, nil
+	}
+
+	if p.peek() == '=' {
+		as.operator = AttributeOperatorEquals
+		p.pos++
+	} else if strings.IndexByte("~|^$*", p.peek()) >= 0 {
+		p.pos++
+		if p.isEnd() {
+			return nil, fmt.Errorf(ErrfUnexpectedEnd, p.text)
+		}
+		if p.peek() != '=' {
+			return nil, p.unexpected()
+		}
+		as.operator = p.text[p.pos-1 : p.pos+1]
+		p.pos++
+	} else {
+		return nil, p.unexpected()
+	}
+
+	p.skipSpaces()
+	if p.isEnd() {
+		return nil, fmt.Errorf(ErrfUnexpectedEnd, p.text)
+	}
+	if (p.peek() == '"') || (p.peek() == '\'') {
+		as.value, err = p.readString()
+	} else {
+		as.value, err = p.readRequiredName()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Flags.
+	p.skipSpaces()
+	if !p.isEnd() && ((p.peek() == 'i') || (p.peek() == 'I')) {
+		as.ignoreCase = true
+		p.pos++
+	} else if !p.isEnd() && ((p.peek() == 's') || (p.peek() == 'S')) {
+		p.pos++
+	}
+
+	p.skipSpaces()
+	if p.isEnd() {
+		return nil, fmt.Errorf(ErrfUnexpectedEnd, p.text)
+	}
+	if p.peek() != ']' {
+		return nil, p.unexpected()
+	}
+	p.pos++
+
+	return as, nil
+}
+
+// parsePseudoClass reads a pseudo-class after the colon.
+func (p *parser) parsePseudoClass() (ns *nthSelector, err error) {
+	start := p.pos
+	name := strings.ToLower(p.readName())
+
+	switch name {
+	case PseudoClassFirstChild:
+		return &nthSelector{a: 0, b: 1}, nil
+	case PseudoClassLastChild:
+		return &nthSelector{a: 0, b: 1, fromEnd: true}, nil
+	case PseudoClassNthChild, PseudoClassNthLastChild:
+	default:
+		return nil, fmt.Errorf(ErrfUnsupportedSelector, start, p.text)
+	}
+
+	if p.isEnd() {
+		return nil, fmt.Errorf(ErrfUnexpectedEnd, p.text)
+	}
+	if p.peek() != '(' {
+		return nil, p.unexpected()
+	}
+	p.pos++
+
+	end := strings.IndexByte(p.text[p.pos:], ')')
+	if end < 0 {
+		return nil, fmt.Errorf(ErrfUnexpectedEnd, p.text)
+	}
+	expr := p.text[p.pos : p.pos+end]
+	p.pos += end + 1
+
+	ns, err = parseNth(expr)
+	if err != nil {
+		return nil, err
+	}
+	ns.fromEnd = name == PseudoClassNthLastChild
+
+	return ns, nil
+}
+
+// parseNth parses the 'an+b' expression, 'odd' or 'even'.
+func parseNth(expr string) (ns *nthSelector, err error) {
+	s := strings.ToLower(strings.Join(strings.Fields(expr), ""))
+
+	switch s {
+	case "":
+		return nil, fmt.Errorf(ErrfBadNthExpression, expr)
+	case NthOdd:
+		return &nthSelector{a: 2, b: 1}, nil
+	case NthEven:
+		return &nthSelector{a: 2, b: 0}, nil
+	}
+
+	ns = &nthSelector{}
+	aStr, bStr, hasN := strings.Cut(s, "n")
+	if !hasN {
+		aStr, bStr = "0", s
+	}
+
+	switch aStr {
+	case "", "+":
+		ns.a = 1
+	case "-":
+		ns.a = -1
+	default:
+		ns.a, err = strconv.Atoi(aStr)
+		if err != nil {
+			return nil, fmt.Errorf(ErrfBadNthExpression, expr)
+		}
+	}
+
+	if len(bStr) > 0 {
+		if hasN && (bStr[0] != '+') && (bStr[0] != '-') {
+			return nil, fmt.Errorf(ErrfBadNthExpression, expr)
+		}
+		ns.b, err = strconv.Atoi(bStr)
+		if err != nil {
+			return nil, fmt.Errorf(ErrfBadNthExpression, expr)
+		}
+	}
+
+	return ns, nil
+}
+
+func (p *parser) readName() string {
+	start := p.pos
+	for !p.isEnd() && isNameSymbol(p.peek()) {
+		p.pos++
+	}
+
+	return p.text[start:p.pos]
+}
+
+func (p *parser) readRequiredName() (name string, err error) {
+	name = p.readName()
+	if len(name) > 0 {
+		return name, nil
+	}
+
+	if p.isEnd() {
+		return "", fmt.Errorf(ErrfUnexpectedEnd, p.text)
+	}
+
+	return "", p.unexpected()
+}
+
+// readString reads a quoted string. A backslash escapes the next symbol.
+func (p *parser) readString() (s string, err error) {
+	quote := p.peek()
+	p.pos++
+
+	var sb strings.Builder
+	for !p.isEnd() {
+		b := p.peek()
+		p.pos++
+
+		switch b {
+		case quote:
+			return sb.String(), nil
+		case '\\':
+			if p.isEnd() {
+				return "", fmt.Errorf(ErrfUnexpectedEnd, p.text)
+			}
+			sb.WriteByte(p.peek())
+			p.pos++
+		default:
+			sb.WriteByte(b)
+		}
+	}
+
+	return "", fmt.Errorf(ErrfUnexpectedEnd, p.text)
+}
+
+// skipSpaces skips white space and tells whether there was any.
+func (p *parser) skipSpaces() (hasSpace bool) {
+	for !p.isEnd() && isSpace(p.peek()) {
+		p.pos++
+		hasSpace = true
+	}
+
+	return hasSpace
+}
+
+func (p *parser) peek() byte {
+	return p.text[p.pos]
+}
+
+func (p *parser) isEnd() bool {
+	return p.pos >= len(p.text)
+}
+
+func (p *parser) unexpected() error {
+	return fmt.Errorf(ErrfUnexpectedSymbol, p.pos, p.text)
+}
+
+func isNameSymbol(b byte) bool {
+	return ((b >= 'a') && (b <= 'z')) || ((b >= 'A') && (b <= 'Z')) || ((b >= '0') && (b <= '9')) ||
+		(b == '-') || (b == '_') || (b >= 0x80)
+}
+
+func isSpace(b byte) bool {
+	return (b == ' ') || (b == '\t') || (b == '\n') || (b == '\r') || (b == '\f')
+}
+
+// parentElement returns the parent of the node if it is an element.
+func parentElement(n *html.Node) *html.Node {
+	p := n.Parent
+	if (p == nil) || (p.Type != html.ElementNode) {
+		return nil
+	}
+
+	return p
+}
+
+// walkDescendants calls the function for all the descendants of the node in
+// the order of the document until the function returns false.
+func walkDescendants(n *html.Node, fn func(n *html.Node) bool) bool {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if !fn(c) || !walkDescendants(c, fn) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
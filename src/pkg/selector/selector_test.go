@@ -0,0 +1,203 @@
+package selector
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	htmldom "github.com/vault-thirteen/auxie/HTML-DOM"
+	"golang.org/x/net/html"
+)
+
+const TestDocument = `<html><body>
+<div id="root" class="forum main">
+	<ul id="list">
+		<li id="i1" lang="en-US" data-words="Alpha beta"></li>
+		<li id="i2" lang="en" title="Topic"></li>
+		<li id="i3"><span id="s3"><a id="a3" href="viewtopic.php?t=3"></a></span></li>
+		<li id="i4" class="pg"><a id="a4" class="pg" href="viewforum.php?f=4"></a></li>
+		<li id="i5"></li>
+	</ul>
+	<p id="p"><b id="b1"><b id="b2"></b></b></p>
+</div>
+</body></html>`
+
+func Test_Select(t *testing.T) {
+	doc := mustParse(t, TestDocument)
+
+	tests := []struct {
+		selector string
+		ids      []string
+	}{
+		// Type, universal, ID and class selectors.
+		{selector: "li#i2", ids: []string{"i2"}},
+		{selector: "div.forum.main", ids: []string{"root"}},
+		{selector: "div.forum.other", ids: []string{}},
+		{selector: "#list > *", ids: []string{"i1", "i2", "i3", "i4", "i5"}},
+
+		// Attribute operators.
+		{selector: "li[title]", ids: []string{"i2"}},
+		{selector: "li[lang=en]", ids: []string{"i2"}},
+		{selector: `li[data-words~="beta"]`, ids: []string{"i1"}},
+		{selector: "li[data-words~=alp]", ids: []string{}},
+		{selector: "li[lang|=en]", ids: []string{"i1", "i2"}},
+		{selector: "a[href^=viewtopic]", ids: []string{"a3"}},
+		{selector: "a[href$='f=4']", ids: []string{"a4"}},
+		{selector: "a[href*=forum]", ids: []string{"a4"}},
+		{selector: "a[href^='']", ids: []string{}},
+		{selector: "li[title=topic]", ids: []string{}},
+		{selector: "li[title=topic i]", ids: []string{"i2"}},
+		{selector: "li[data-words~=ALPHA I]", ids: []string{"i1"}},
+		{selector: "li[title=Topic s]", ids: []string{"i2"}},
+
+		// Descendant and child combinators.
+		{selector: "li a", ids: []string{"a3", "a4"}},
+		{selector: "li > a", ids: []string{"a4"}},
+		{selector: "div#root a", ids: []string{"a3", "a4"}},
+		{selector: "div > a", ids: []string{}},
+		{selector: "p > b > b", ids: []string{"b2"}},
+		{selector: "p > b", ids: []string{"b1"}},
+		{selector: "p b", ids: []string{"b1", "b2"}},
+
+		// Positions.
+		{selector: "li:first-child", ids: []string{"i1"}},
+		{selector: "li:last-child", ids: []string{"i5"}},
+		{selector: "li:nth-child(odd)", ids: []string{"i1", "i3", "i5"}},
+		{selector: "li:nth-child(even)", ids: []string{"i2", "i4"}},
+		{selector: "li:nth-child(2n+1)", ids: []string{"i1", "i3", "i5"}},
+		{selector: "li:nth-child(-n+3)", ids: []string{"i1", "i2", "i3"}},
+		{selector: "li:nth-child( 3 )", ids: []string{"i3"}},
+		{selector: "li:nth-child(n+4)", ids: []string{"i4", "i5"}},
+		{selector: "li:nth-last-child(1)", ids: []string{"i5"}},
+		{selector: "li:nth-last-child(2n)", ids: []string{"i2", "i4"}},
+
+		// Groups are matched in the order of the document.
+		{selector: "a.pg, li#i1", ids: []string{"i1", "a4"}},
+		{selector: "b, span, b", ids: []string{"s3", "b1", "b2"}},
+	}
+
+	for _, test := range tests {
+		s, err := Compile(test.selector)
+		if err != nil {
+			t.Errorf("selector %q: %v", test.selector, err)
+			continue
+		}
+
+		ids := getIds(s.Select(doc))
+		if !reflect.DeepEqual(ids, test.ids) {
+			t.Errorf("selector %q:\nactual:   %v\nexpected: %v", test.selector, ids, test.ids)
+		}
+	}
+}
+
+func Test_SelectFirst(t *testing.T) {
+	doc := mustParse(t, TestDocument)
+
+	// Test #1. The first node in the order of the document.
+	n := MustCompile("li a").SelectFirst(doc)
+	if (n == nil) || (getIds([]*html.Node{n})[0] != "a3") {
+		t.Errorf("a wrong node is selected: %v", n)
+	}
+
+	// Test #2. Nothing is found.
+	n = MustCompile("table").SelectFirst(doc)
+	if n != nil {
+		t.Errorf("a node is selected: %v", n)
+	}
+}
+
+func Test_Compile_Errors(t *testing.T) {
+	tests := []struct {
+		selector string
+		err      string
+	}{
+		{selector: "", err: ErrSelectorIsEmpty},
+		{selector: "  ", err: ErrSelectorIsEmpty},
+		{selector: "a,", err: "unexpected end"},
+		{selector: ",a", err: "unexpected symbol"},
+		{selector: "a >", err: "unexpected end"},
+		{selector: "> a", err: "unexpected symbol"},
+		{selector: "a + b", err: "unsupported selector"},
+		{selector: "a ~ b", err: "unsupported selector"},
+		{selector: "a:hover", err: "unsupported selector"},
+		{selector: "a:", err: "unsupported selector"},
+		{selector: "a#", err: "unexpected end"},
+		{selector: "a.", err: "unexpected end"},
+		{selector: "a..b", err: "unexpected symbol"},
+		{selector: "a[", err: "unexpected end"},
+		{selector: "a[]", err: "unexpected symbol"},
+		{selector: "a[id", err: "unexpected end"},
+		{selector: "a[id=", err: "unexpected end"},
+		{selector: "a[id=x", err: "unexpected end"},
+		{selector: "a[id!=x]", err: "unexpected symbol"},
+		{selector: "a[id^x]", err: "unexpected symbol"},
+		{selector: "a[id^", err: "unexpected end"},
+		{selector: "a[id='x]", err: "unexpected end"},
+		{selector: `a[id='x\`, err: "unexpected end"},
+		{selector: "a[id=x y]", err: "unexpected symbol"},
+		{selector: "li:nth-child", err: "unexpected end"},
+		{selector: "li:nth-child 2", err: "unexpected symbol"},
+		{selector: "li:nth-child(2", err: "unexpected end"},
+		{selector: "li:nth-child()", err: "bad expression"},
+		{selector: "li:nth-child(x)", err: "bad expression"},
+		{selector: "li:nth-child(2n1)", err: "bad expression"},
+		{selector: "li:nth-child(an+1)", err: "bad expression"},
+		{selector: "a!", err: "unexpected symbol"},
+	}
+
+	for _, test := range tests {
+		s, err := compileWithoutPanic(test.selector)
+		if err == nil {
+			t.Errorf("selector %q: error is expected, got %v", test.selector, s)
+			continue
+		}
+		if !strings.Contains(err.Error(), test.err) {
+			t.Errorf("selector %q: error %q does not contain %q", test.selector, err.Error(), test.err)
+		}
+	}
+}
+
+func Test_MustCompile_Panic(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("panic is expected")
+		}
+	}()
+
+	MustCompile("a[")
+}
+
+func mustParse(t *testing.T, text string) (doc *html.Node) {
+	t.Helper()
+
+	doc, err := html.Parse(strings.NewReader(text))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return doc
+}
+
+// compileWithoutPanic compiles a selector turning a panic into an error, so
+// that all the malformed selectors are checked.
+func compileWithoutPanic(text string) (s *Selector, err error) {
+	defer func() {
+		r := recover()
+		if r != nil {
+			s, err = nil, fmt.Errorf("panic: %v", r)
+		}
+	}()
+
+	return Compile(text)
+}
+
+func getIds(nodes []*html.Node) (ids []string) {
+	ids = make([]string, 0, len(nodes))
+	for _, n := range nodes {
+		id, _ := htmldom.GetNodeAttributeValue(n, htmldom.AttributeId)
+		ids = append(ids, id)
+	}
+
+	return ids
+}
@@ -0,0 +1,454 @@
+package web
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	neturl "net/url"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/andybalholm/brotli"
+	"github.com/vault-thirteen/Forum-Crawler/src/models"
+	ae "github.com/vault-thirteen/auxie/errors"
+)
+
+const (
+	ErrAttemptsAreExhausted       = "all %v attempts have failed, last error: %w"
+	ErrUnsupportedContentEncoding = "unsupported content encoding: %v"
+)
+
+const (
+	DefaultConnectTimeoutSec = 10
+	DefaultReadTimeoutSec    = 30
+	DefaultRequestTimeoutSec = 120
+	DefaultMaxAttempts       = 5
+	DefaultRetryDelaySec     = 1
+	DefaultRetryMaxDelaySec  = 60
+	KeepAlivePeriod          = 30 * time.Second
+)
+
+const (
+	HttpHeaderCookie      = "Cookie"
+	HttpHeaderUserAgent   = "User-Agent"
+	HttpHeaderRetryAfter  = "Retry-After"
+	HttpHeaderContentType = "Content-Type"
+	ContentTypeForm       = "application/x-www-form-urlencoded"
+
+	HttpHeaderAcceptEncoding  = "Accept-Encoding"
+	HttpHeaderContentEncoding = "Content-Encoding"
+	HttpHeaderETag            = "ETag"
+	HttpHeaderLastModified    = "Last-Modified"
+	HttpHeaderIfNoneMatch     = "If-None-Match"
+	HttpHeaderIfModifiedSince = "If-Modified-Since"
+)
+
+const (
+	ContentEncodingGzip     = "gzip"
+	ContentEncodingBrotli   = "br"
+	ContentEncodingIdentity = "identity"
+	AcceptedEncodings       = ContentEncodingGzip + ", " + ContentEncodingBrotli
+)
+
+// Response is a fully read HTTP response. Compressed bodies are decompressed.
+type Response struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+}
+
+// IsNotModified checks whether a conditional request has found that the page
+// has not been modified. Such a response has no body.
+func (r *Response) IsNotModified() bool {
+	return r.StatusCode == http.StatusNotModified
+}
+
+// Validators returns validators of the version of the page in the response.
+func (r *Response) Validators() (v *Validators) {
+	return &Validators{
+		ETag:         r.Header.Get(HttpHeaderETag),
+		LastModified: r.Header.Get(HttpHeaderLastModified),
+	}
+}
+
+// Validators identify a version of a page for conditional requests.
+type Validators struct {
+	ETag         string
+	LastModified string
+}
+
+// IsEmpty checks whether there are no validators, i.e. a conditional request
+// is not possible.
+func (v *Validators) IsEmpty() bool {
+	return (v == nil) || ((len(v.ETag) == 0) && (len(v.LastModified) == 0))
+}
+
+// Client is an HTTP client which retries failed requests with an exponential
+// backoff. Transient network errors, e.g. timeouts and reset connections,
+// '429 Too Many Requests' and server errors are retried. Other errors, e.g.
+// malformed URLs or bad certificates, fail at once. Responses having other
+// status codes than '200 OK' are returned as a 'StatusError'.
+type Client struct {
+	httpClient    *http.Client
+	rateLimiter   *RateLimiter
+	proxyPool     *ProxyPool
+	header        http.Header
+	compression   bool
+	maxAttempts   uint
+	retryDelay    time.Duration
+	retryMaxDelay time.Duration
+}
+
+// NewClient creates an HTTP client. Settings may be nil, zero settings are
+// replaced by default values. Header is added to each request. Cookie jar is
+// optional.
+func NewClient(settings *models.HttpSettings, header http.Header, jar http.CookieJar) (c *Client, err error) {
+	s := models.HttpSettings{}
+	if settings != nil {
+		s = *settings
+	}
+
+	connectTimeout := secondsOrDefault(s.ConnectTimeoutSec, DefaultConnectTimeoutSec)
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = (&net.Dialer{
+		Timeout:   connectTimeout,
+		KeepAlive: KeepAlivePeriod,
+	}).DialContext
+	transport.TLSHandshakeTimeout = connectTimeout
+	transport.ResponseHeaderTimeout = secondsOrDefault(s.ReadTimeoutSec, DefaultReadTimeoutSec)
+
+	// Compressed responses are decompressed by the client itself, as the
+	// transport supports only gzip.
+	transport.DisableCompression = true
+
+	c = &Client{
+		httpClient: &http.Client{
+			Transport: transport,
+			Jar:       jar,
+			Timeout:   secondsOrDefault(s.RequestTimeoutSec, DefaultRequestTimeoutSec),
+		},
+		header:        header,
+		compression:   !s.DisableCompression,
+		maxAttempts:   s.MaxAttempts,
+		retryDelay:    secondsOrDefault(s.RetryDelaySec, DefaultRetryDelaySec),
+		retryMaxDelay: secondsOrDefault(s.RetryMaxDelaySec, DefaultRetryMaxDelaySec),
+	}
+
+	if c.maxAttempts == 0 {
+		c.maxAttempts = DefaultMaxAttempts
+	}
+
+	proxyUrls := s.Proxies
+	if len(s.Proxy) > 0 {
+		proxyUrls = append([]string{s.Proxy}, proxyUrls...)
+	}
+	if len(proxyUrls) > 0 {
+		c.proxyPool, err = NewProxyPool(proxyUrls, s.ProxyRotation, s.ProxyMaxFailures,
+			secondsOrDefault(s.ProxyCooldownSec, DefaultProxyCooldownSec))
+		if err != nil {
+			return nil, err
+		}
+		transport.Proxy = proxyOfRequest
+	}
+
+	if s.RequestsPerSecond > 0 {
+		c.rateLimiter = NewRateLimiter(s.RequestsPerSecond, s.Burst, s.MinRequestsPerSecond,
+			secondsOrDefault(s.SlowResponseSec, DefaultSlowResponseSec))
+	}
+
+	return c, nil
+}
+
+// Get fetches a page. Failed attempts are retried according to the retry
+// policy of the client. Each attempt waits for the rate limiter, if it is
+// enabled.
+func (c *Client) Get(url string) (resp *Response, err error) {
+	return c.GetIfModified(url, nil)
+}
+
+// GetIfModified fetches a page unless it has not been modified since the
+// version identified by validators. Not modified page is returned as a
+// response with the '304 Not Modified' status. Empty validators make an
+// ordinary request.
+func (c *Client) GetIfModified(url string, validators *Validators) (resp *Response, err error) {
+	var retryAfter time.Duration
+	for attempt := uint(1); ; attempt++ {
+		resp, retryAfter, err = c.do(http.MethodGet, url, nil, validators)
+		if err == nil {
+			return resp, nil
+		}
+
+		var nre *nonRetryableError
+		if errors.As(err, &nre) {
+			return nil, nre.err
+		}
+
+		if attempt >= c.maxAttempts {
+			return nil, fmt.Errorf(ErrAttemptsAreExhausted, attempt, err)
+		}
+
+		// Servers may ask for very long delays, they are limited as usual
+		// delays are.
+		if retryAfter > c.retryMaxDelay {
+			log.Println(fmt.Sprintf("Server has asked to retry in %v, the delay is limited to %v.", retryAfter, c.retryMaxDelay))
+			retryAfter = c.retryMaxDelay
+		}
+
+		delay := max(c.getBackoffDelay(attempt), retryAfter)
+		log.Println(fmt.Sprintf("Attempt %v of %v has failed: %v. Retrying in %v.", attempt, c.maxAttempts, err.Error(), delay))
+		time.Sleep(delay)
+	}
+}
+
+// PostForm submits a form. Forms are not retried, as their submission may
+// change the state of a server.
+func (c *Client) PostForm(url string, form neturl.Values) (resp *Response, err error) {
+	resp, _, err = c.do(http.MethodPost, url, form, nil)
+	if err != nil {
+		var nre *nonRetryableError
+		if errors.As(err, &nre) {
+			return nil, nre.err
+		}
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+// do makes a single attempt to send a request. Form is sent only by POST
+// requests, validators make a GET request conditional. Retryable failures are
+// returned as errors together with the delay requested by the server, if any.
+func (c *Client) do(method string, url string, form neturl.Values, validators *Validators) (resp *Response, retryAfter time.Duration, err error) {
+	var body io.Reader
+	if form != nil {
+		body = strings.NewReader(form.Encode())
+	}
+
+	var req *http.Request
+	req, err = http.NewRequest(method, url, body)
+	if err != nil {
+		return nil, 0, &nonRetryableError{err: err}
+	}
+
+	if form != nil {
+		req.Header.Set(HttpHeaderContentType, ContentTypeForm)
+	}
+
+	for name, values := range c.header {
+		req.Header[name] = values
+	}
+
+	if c.compression {
+		req.Header.Set(HttpHeaderAcceptEncoding, AcceptedEncodings)
+	}
+
+	if !validators.IsEmpty() {
+		if len(validators.ETag) > 0 {
+			req.Header.Set(HttpHeaderIfNoneMatch, validators.ETag)
+		}
+		if len(validators.LastModified) > 0 {
+			req.Header.Set(HttpHeaderIfModifiedSince, validators.LastModified)
+		}
+	}
+
+	var p *proxy
+	if c.proxyPool != nil {
+		req, p = c.proxyPool.withProxy(req)
+	}
+
+	if c.rateLimiter != nil {
+		c.rateLimiter.Wait()
+	}
+
+	var httpResp *http.Response
+	startedAt := time.Now()
+	httpResp, err = c.httpClient.Do(req)
+	if p != nil {
+		c.proxyPool.report(p, isProxyFailure(httpResp, err))
+	}
+	if c.rateLimiter != nil {
+		var statusCode int
+		if httpResp != nil {
+			statusCode = httpResp.StatusCode
+		}
+		c.rateLimiter.Report(time.Since(startedAt), statusCode)
+	}
+	if err != nil {
+		return nil, 0, classifyError(err)
+	}
+	defer func() {
+		derr := httpResp.Body.Close()
+		if derr != nil {
+			err = ae.Combine(err, derr)
+		}
+	}()
+
+	isNotModified := (httpResp.StatusCode == http.StatusNotModified) && !validators.IsEmpty()
+	if (httpResp.StatusCode != http.StatusOK) && !isNotModified {
+		statusErr := &StatusError{
+			Url:        url,
+			StatusCode: httpResp.StatusCode,
+			Status:     httpResp.Status,
+		}
+
+		if isRetryableStatus(httpResp.StatusCode) {
+			return nil, parseRetryAfter(httpResp.Header.Get(HttpHeaderRetryAfter)), statusErr
+		}
+
+		return nil, 0, &nonRetryableError{err: statusErr}
+	}
+
+	resp = &Response{
+		StatusCode: httpResp.StatusCode,
+		Header:     httpResp.Header,
+	}
+
+	if isNotModified {
+		return resp, 0, nil
+	}
+
+	resp.Body, err = readBody(httpResp)
+	if err != nil {
+		return nil, 0, classifyError(err)
+	}
+
+	return resp, 0, nil
+}
+
+// readBody reads the body of a response decompressing it.
+func readBody(httpResp *http.Response) (body []byte, err error) {
+	contentEncoding := strings.ToLower(strings.TrimSpace(httpResp.Header.Get(HttpHeaderContentEncoding)))
+
+	var r io.Reader
+	switch contentEncoding {
+	case "", ContentEncodingIdentity:
+		r = httpResp.Body
+	case ContentEncodingGzip:
+		var gr *gzip.Reader
+		gr, err = gzip.NewReader(httpResp.Body)
+		if err != nil {
+			return nil, err
+		}
+		defer func() {
+			derr := gr.Close()
+			if derr != nil {
+				err = ae.Combine(err, derr)
+			}
+		}()
+		r = gr
+	case ContentEncodingBrotli:
+		r = brotli.NewReader(httpResp.Body)
+	default:
+		return nil, &nonRetryableError{err: fmt.Errorf(ErrUnsupportedContentEncoding, contentEncoding)}
+	}
+
+	return io.ReadAll(r)
+}
+
+// getBackoffDelay returns a delay before the next attempt. The delay grows
+// exponentially and is randomised by a jitter of up to a half of its value,
+// so that parallel clients do not retry simultaneously.
+func (c *Client) getBackoffDelay(attempt uint) (delay time.Duration) {
+	delay = c.retryDelay
+	for i := uint(1); (i < attempt) && (delay < c.retryMaxDelay); i++ {
+		delay *= 2
+	}
+	delay = min(delay, c.retryMaxDelay)
+
+	half := delay / 2
+	if half <= 0 {
+		return delay
+	}
+
+	return half + rand.N(half+1)
+}
+
+// nonRetryableError is an error of an attempt which should not be retried.
+type nonRetryableError struct {
+	err error
+}
+
+func (e *nonRetryableError) Error() string {
+	return e.err.Error()
+}
+
+// classifyError marks an error of a request as non-retryable unless it is a
+// transient network error.
+func classifyError(err error) error {
+	var nre *nonRetryableError
+	if errors.As(err, &nre) || isTransientError(err) {
+		return err
+	}
+
+	return &nonRetryableError{err: err}
+}
+
+// isTransientError checks whether a request failed with the error may succeed
+// later. Such errors are network errors, e.g. timeouts, refused and reset
+// connections, and connections closed by a server in the middle of a
+// response. 'url.Error' is a 'net.Error' itself, so that the error it wraps is
+// checked.
+func isTransientError(err error) bool {
+	var urlErr *neturl.Error
+	for errors.As(err, &urlErr) {
+		err = urlErr.Err
+	}
+
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED) {
+		return true
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+// isRetryableStatus checks whether a request failed with a status code may
+// succeed later.
+func isRetryableStatus(statusCode int) bool {
+	if statusCode == http.StatusTooManyRequests {
+		return true
+	}
+
+	return (statusCode >= http.StatusInternalServerError) &&
+		(statusCode != http.StatusNotImplemented) &&
+		(statusCode != http.StatusHTTPVersionNotSupported)
+}
+
+// parseRetryAfter parses the value of a 'Retry-After' HTTP header which is
+// either a number of seconds or a date. Zero is returned for invalid and past
+// values.
+func parseRetryAfter(value string) (delay time.Duration) {
+	if len(value) == 0 {
+		return 0
+	}
+
+	seconds, err := strconv.ParseUint(value, 10, 32)
+	if err == nil {
+		return time.Duration(seconds) * time.Second
+	}
+
+	var t time.Time
+	t, err = http.ParseTime(value)
+	if err != nil {
+		return 0
+	}
+
+	return max(time.Until(t), 0)
+}
+
+func secondsOrDefault(seconds float64, defaultSeconds float64) time.Duration {
+	if seconds <= 0 {
+		seconds = defaultSeconds
+	}
+
+	return time.Duration(float64(time.Second) * seconds)
+}
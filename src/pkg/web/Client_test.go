@@ -0,0 +1,122 @@
+package web
+
+import (
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	neturl "net/url"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"testing"
+
+	"github.com/vault-thirteen/Forum-Crawler/src/models"
+)
+
+func Test_isTransientError(t *testing.T) {
+	_, parseErr := neturl.Parse("http://a b.org/%zz")
+
+	tests := []struct {
+		name        string
+		err         error
+		isTransient bool
+	}{
+		{name: "timeout", err: &neturl.Error{Op: "Get", Err: &net.DNSError{IsTimeout: true}}, isTransient: true},
+		{name: "refused", err: &neturl.Error{Op: "Get", Err: &net.OpError{Op: "dial", Err: syscall.ECONNREFUSED}}, isTransient: true},
+		{name: "reset", err: fmt.Errorf("read: %w", syscall.ECONNRESET), isTransient: true},
+		{name: "closed", err: &neturl.Error{Op: "Get", Err: io.EOF}, isTransient: true},
+		{name: "truncated body", err: io.ErrUnexpectedEOF, isTransient: true},
+		{name: "malformed URL", err: parseErr, isTransient: false},
+		{name: "scheme", err: &neturl.Error{Op: "Get", Err: errors.New("unsupported protocol scheme \"ftp\"")}, isTransient: false},
+		{name: "certificate", err: &neturl.Error{Op: "Get", Err: x509.UnknownAuthorityError{}}, isTransient: false},
+		{name: "other", err: errors.New("gzip: invalid header"), isTransient: false},
+	}
+
+	for _, test := range tests {
+		if isTransientError(test.err) != test.isTransient {
+			t.Errorf("%v: %v is expected to be transient: %v", test.name, test.err, test.isTransient)
+		}
+	}
+}
+
+func Test_Client_Get_Retries(t *testing.T) {
+	var attempts atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Test #1. The connection is closed without a response.
+		if attempts.Add(1) == 1 {
+			conn, _, err := w.(http.Hijacker).Hijack()
+			if err == nil {
+				_ = conn.Close()
+			}
+			return
+		}
+
+		// Test #2. Server error.
+		if attempts.Load() == 2 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	c := mustCreateClient(t)
+	resp, err := c.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if (string(resp.Body) != "ok") || (attempts.Load() != 3) {
+		t.Errorf("unexpected response %q after %v attempts", resp.Body, attempts.Load())
+	}
+}
+
+func Test_Client_Get_FailsFast(t *testing.T) {
+	var attempts atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts.Add(1)
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	c := mustCreateClient(t)
+	tests := []struct {
+		url      string
+		attempts int32
+	}{
+		{url: "http://a b.org/", attempts: 0},
+		{url: "ftp://127.0.0.1/", attempts: 0},
+		{url: server.URL, attempts: 1},
+	}
+
+	for _, test := range tests {
+		attempts.Store(0)
+		_, err := c.Get(test.url)
+		if err == nil {
+			t.Errorf("%v: error is expected", test.url)
+			continue
+		}
+		if strings.Contains(err.Error(), "attempts have failed") || (attempts.Load() != test.attempts) {
+			t.Errorf("%v: the request is retried: %v", test.url, err)
+		}
+	}
+}
+
+func mustCreateClient(t *testing.T) (c *Client) {
+	t.Helper()
+
+	c, err := NewClient(&models.HttpSettings{
+		MaxAttempts:      3,
+		RetryDelaySec:    0.001,
+		RetryMaxDelaySec: 0.001,
+	}, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return c
+}
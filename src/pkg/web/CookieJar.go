@@ -0,0 +1,177 @@
+package web
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+
+	"golang.org/x/net/publicsuffix"
+)
+
+const (
+	CookieFilePermissions = 0600
+)
+
+// CookieJar is a cookie jar saved into a file, so that a session survives
+// restarts of the crawler. The file is rewritten each time a server sets
+// cookies.
+type CookieJar struct {
+	mu      sync.Mutex
+	jar     *cookiejar.Jar
+	file    string
+	cookies map[string]*savedCookie
+}
+
+// savedCookie is a cookie together with the URL which has set it.
+type savedCookie struct {
+	Url      string    `json:"url"`
+	Name     string    `json:"name"`
+	Value    string    `json:"value"`
+	Domain   string    `json:"domain,omitempty"`
+	Path     string    `json:"path,omitempty"`
+	Expires  time.Time `json:"expires,omitempty"`
+	Secure   bool      `json:"secure,omitempty"`
+	HttpOnly bool      `json:"httpOnly,omitempty"`
+}
+
+// NewCookieJar creates a cookie jar and loads cookies from the file, if it
+// exists. Empty file name means that cookies are kept only in memory.
+func NewCookieJar(file string) (cj *CookieJar, err error) {
+	cj = &CookieJar{
+		file:    file,
+		cookies: make(map[string]*savedCookie),
+	}
+
+	cj.jar, err = cookiejar.New(&cookiejar.Options{PublicSuffixList: publicsuffix.List})
+	if err != nil {
+		return nil, err
+	}
+
+	err = cj.load()
+	if err != nil {
+		return nil, err
+	}
+
+	return cj, nil
+}
+
+func (cj *CookieJar) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	cj.jar.SetCookies(u, cookies)
+
+	if len(cj.file) == 0 {
+		return
+	}
+
+	cj.mu.Lock()
+	defer cj.mu.Unlock()
+
+	for _, c := range cookies {
+		sc := &savedCookie{
+			Url:      u.String(),
+			Name:     c.Name,
+			Value:    c.Value,
+			Domain:   c.Domain,
+			Path:     c.Path,
+			Expires:  c.Expires,
+			Secure:   c.Secure,
+			HttpOnly: c.HttpOnly,
+		}
+		if c.MaxAge > 0 {
+			sc.Expires = time.Now().Add(time.Duration(c.MaxAge) * time.Second)
+		}
+
+		key := u.Host + " " + c.Domain + " " + c.Path + " " + c.Name
+		if (c.MaxAge < 0) || (len(c.Value) == 0) {
+			delete(cj.cookies, key)
+		} else {
+			cj.cookies[key] = sc
+		}
+	}
+
+	// Saving errors are not fatal, the session stays in memory.
+	_ = cj.save()
+}
+
+func (cj *CookieJar) Cookies(u *url.URL) []*http.Cookie {
+	return cj.jar.Cookies(u)
+}
+
+// HasCookies checks whether the jar has cookies for the URL.
+func (cj *CookieJar) HasCookies(rawUrl string) bool {
+	u, err := url.Parse(rawUrl)
+	if err != nil {
+		return false
+	}
+
+	return len(cj.jar.Cookies(u)) > 0
+}
+
+// load reads cookies from the file. Expired cookies are dropped.
+func (cj *CookieJar) load() (err error) {
+	if len(cj.file) == 0 {
+		return nil
+	}
+
+	var buf []byte
+	buf, err = os.ReadFile(cj.file)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+
+	var saved []*savedCookie
+	err = json.Unmarshal(buf, &saved)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for _, sc := range saved {
+		if !sc.Expires.IsZero() && sc.Expires.Before(now) {
+			continue
+		}
+
+		var u *url.URL
+		u, err = url.Parse(sc.Url)
+		if err != nil {
+			return err
+		}
+
+		cj.jar.SetCookies(u, []*http.Cookie{{
+			Name:     sc.Name,
+			Value:    sc.Value,
+			Domain:   sc.Domain,
+			Path:     sc.Path,
+			Expires:  sc.Expires,
+			Secure:   sc.Secure,
+			HttpOnly: sc.HttpOnly,
+		}})
+		cj.cookies[u.Host+" "+sc.Domain+" "+sc.Path+" "+sc.Name] = sc
+	}
+
+	return nil
+}
+
+// save writes cookies into the file. Mutex must be locked by the caller.
+func (cj *CookieJar) save() (err error) {
+	saved := make([]*savedCookie, 0, len(cj.cookies))
+	for _, sc := range cj.cookies {
+		saved = append(saved, sc)
+	}
+
+	var buf []byte
+	buf, err = json.MarshalIndent(saved, "", "\t")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(cj.file, buf, CookieFilePermissions)
+}
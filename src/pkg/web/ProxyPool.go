@@ -0,0 +1,190 @@
+package web
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/vault-thirteen/Forum-Crawler/src/models"
+)
+
+const (
+	ErrUnsupportedProxyScheme   = "unsupported proxy scheme: %v"
+	ErrUnsupportedProxyRotation = "unsupported proxy rotation: %v"
+)
+
+const (
+	ProxySchemeHttp    = "http"
+	ProxySchemeHttps   = "https"
+	ProxySchemeSocks5  = "socks5"
+	ProxySchemeSocks5h = "socks5h"
+)
+
+const (
+	DefaultProxyMaxFailures = 3
+	DefaultProxyCooldownSec = 300
+)
+
+// proxyContextKey is a key of the proxy chosen for a request in the context
+// of the request.
+type proxyContextKey struct{}
+
+// proxy is a proxy server and its health.
+type proxy struct {
+	url *url.URL
+
+	// Proxy is disabled until the moment after a number of failures in a row.
+	failures      uint
+	disabledUntil time.Time
+}
+
+// ProxyPool chooses a proxy for each request. Proxies are either rotated
+// round-robin or used one by one switching to the next proxy on failure.
+// A proxy failed several times in a row is disabled for a while.
+type ProxyPool struct {
+	mu sync.Mutex
+
+	proxies     []*proxy
+	rotation    string
+	current     int
+	maxFailures uint
+	cooldown    time.Duration
+}
+
+// NewProxyPool creates a pool of proxies. Proxies are URLs with 'http',
+// 'https', 'socks5' or 'socks5h' schemes, credentials may be set as user
+// information of URLs. Zero settings are replaced by defaults.
+func NewProxyPool(rawUrls []string, rotation string, maxFailures uint, cooldown time.Duration) (pp *ProxyPool, err error) {
+	switch rotation {
+	case "":
+		rotation = models.ProxyRotation_RoundRobin
+	case models.ProxyRotation_RoundRobin, models.ProxyRotation_OnFailure:
+	default:
+		return nil, fmt.Errorf(ErrUnsupportedProxyRotation, rotation)
+	}
+
+	if maxFailures == 0 {
+		maxFailures = DefaultProxyMaxFailures
+	}
+	if cooldown <= 0 {
+		cooldown = DefaultProxyCooldownSec * time.Second
+	}
+
+	pp = &ProxyPool{
+		proxies:     make([]*proxy, 0, len(rawUrls)),
+		rotation:    rotation,
+		maxFailures: maxFailures,
+		cooldown:    cooldown,
+	}
+
+	for _, rawUrl := range rawUrls {
+		var u *url.URL
+		u, err = url.Parse(rawUrl)
+		if err != nil {
+			return nil, err
+		}
+
+		switch u.Scheme {
+		case ProxySchemeHttp, ProxySchemeHttps, ProxySchemeSocks5, ProxySchemeSocks5h:
+		default:
+			return nil, fmt.Errorf(ErrUnsupportedProxyScheme, u.Scheme)
+		}
+
+		pp.proxies = append(pp.proxies, &proxy{url: u})
+	}
+
+	return pp, nil
+}
+
+// next chooses a proxy for a request. Disabled proxies are skipped. When all
+// proxies are disabled, the proxy which is enabled first is used, so that the
+// crawler does not stop.
+func (pp *ProxyPool) next() (p *proxy) {
+	pp.mu.Lock()
+	defer pp.mu.Unlock()
+
+	now := time.Now()
+	for i := 0; i < len(pp.proxies); i++ {
+		idx := (pp.current + i) % len(pp.proxies)
+		p = pp.proxies[idx]
+		if p.disabledUntil.After(now) {
+			continue
+		}
+
+		if pp.rotation == models.ProxyRotation_RoundRobin {
+			pp.current = (idx + 1) % len(pp.proxies)
+		} else {
+			pp.current = idx
+		}
+		return p
+	}
+
+	p = pp.proxies[0]
+	for _, q := range pp.proxies {
+		if q.disabledUntil.Before(p.disabledUntil) {
+			p = q
+		}
+	}
+
+	return p
+}
+
+// report records the result of a request made through a proxy. Successful
+// requests restore health of the proxy.
+func (pp *ProxyPool) report(p *proxy, isFailed bool) {
+	pp.mu.Lock()
+	defer pp.mu.Unlock()
+
+	if !isFailed {
+		if !p.disabledUntil.IsZero() {
+			log.Println(fmt.Sprintf("Proxy %v is healthy again.", p.url.Redacted()))
+		}
+		p.failures = 0
+		p.disabledUntil = time.Time{}
+		return
+	}
+
+	p.failures++
+
+	// Failed proxy is left in the on-failure rotation.
+	if pp.rotation == models.ProxyRotation_OnFailure && (pp.proxies[pp.current] == p) {
+		pp.current = (pp.current + 1) % len(pp.proxies)
+	}
+
+	if p.failures >= pp.maxFailures {
+		p.disabledUntil = time.Now().Add(pp.cooldown)
+		log.Println(fmt.Sprintf("Proxy %v has failed %v times in a row and is disabled for %v.", p.url.Redacted(), p.failures, pp.cooldown))
+	}
+}
+
+// withProxy attaches a proxy to a request.
+func (pp *ProxyPool) withProxy(req *http.Request) (reqWithProxy *http.Request, p *proxy) {
+	p = pp.next()
+	return req.WithContext(context.WithValue(req.Context(), proxyContextKey{}, p)), p
+}
+
+// proxyOfRequest returns the proxy attached to a request. It is used as the
+// proxy function of the transport.
+func proxyOfRequest(req *http.Request) (proxyUrl *url.URL, err error) {
+	p, ok := req.Context().Value(proxyContextKey{}).(*proxy)
+	if !ok {
+		return nil, nil
+	}
+
+	return p.url, nil
+}
+
+// isProxyFailure checks whether a request has failed because of a proxy.
+// Network errors and '407 Proxy Authentication Required' responses are
+// treated as failures of the proxy.
+func isProxyFailure(resp *http.Response, err error) bool {
+	if err != nil {
+		return true
+	}
+
+	return resp.StatusCode == http.StatusProxyAuthRequired
+}
@@ -0,0 +1,109 @@
+package web
+
+import (
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	// Rate is divided by SlowDownFactor after a '429' or '503' response, a
+	// slow response or a network error, and is increased by a 1/SpeedUpSteps
+	// part of the maximum rate after a healthy response.
+	SlowDownFactor = 2.0
+	SpeedUpSteps   = 20.0
+
+	DefaultMinRateRatio    = 0.1
+	DefaultSlowResponseSec = 5
+)
+
+// RateLimiter is an adaptive token bucket shared by all requests of a client.
+// The bucket is refilled with the current rate which falls when the server is
+// under pressure and grows back up to the maximum rate when it is healthy.
+type RateLimiter struct {
+	mu sync.Mutex
+
+	maxRate      float64
+	minRate      float64
+	rate         float64
+	burst        float64
+	tokens       float64
+	lastRefillAt time.Time
+	slowResponse time.Duration
+}
+
+// NewRateLimiter creates a rate limiter. Rates are measured in requests per
+// second. Zero minimum rate and zero slow response duration are replaced by
+// defaults.
+func NewRateLimiter(maxRate float64, burst uint, minRate float64, slowResponse time.Duration) (rl *RateLimiter) {
+	if burst == 0 {
+		burst = 1
+	}
+	if (minRate <= 0) || (minRate > maxRate) {
+		minRate = maxRate * DefaultMinRateRatio
+	}
+	if slowResponse <= 0 {
+		slowResponse = DefaultSlowResponseSec * time.Second
+	}
+
+	return &RateLimiter{
+		maxRate:      maxRate,
+		minRate:      minRate,
+		rate:         maxRate,
+		burst:        float64(burst),
+		tokens:       float64(burst),
+		lastRefillAt: time.Now(),
+		slowResponse: slowResponse,
+	}
+}
+
+// Wait blocks until a request may be made.
+func (rl *RateLimiter) Wait() {
+	rl.mu.Lock()
+	rl.refill()
+	rl.tokens--
+	var delay time.Duration
+	if rl.tokens < 0 {
+		delay = time.Duration(-rl.tokens / rl.rate * float64(time.Second))
+	}
+	rl.mu.Unlock()
+
+	if delay > 0 {
+		time.Sleep(delay)
+	}
+}
+
+// Report adapts the rate to the result of a request. Status code is zero when
+// a request has failed without a response.
+func (rl *RateLimiter) Report(latency time.Duration, statusCode int) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	rl.refill()
+
+	if (statusCode == 0) ||
+		(statusCode == http.StatusTooManyRequests) ||
+		(statusCode == http.StatusServiceUnavailable) ||
+		(latency > rl.slowResponse) {
+		rl.rate = max(rl.rate/SlowDownFactor, rl.minRate)
+		return
+	}
+
+	rl.rate = min(rl.rate+rl.maxRate/SpeedUpSteps, rl.maxRate)
+}
+
+// Rate returns the current rate in requests per second.
+func (rl *RateLimiter) Rate() float64 {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	return rl.rate
+}
+
+// refill adds tokens accumulated since the last refill. Mutex must be locked
+// by the caller.
+func (rl *RateLimiter) refill() {
+	now := time.Now()
+	rl.tokens = min(rl.tokens+now.Sub(rl.lastRefillAt).Seconds()*rl.rate, rl.burst)
+	rl.lastRefillAt = now
+}
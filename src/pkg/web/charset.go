@@ -0,0 +1,65 @@
+package web
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/net/html/charset"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/htmlindex"
+	"golang.org/x/text/encoding/ianaindex"
+	"golang.org/x/text/encoding/unicode"
+	"golang.org/x/text/transform"
+)
+
+const (
+	ErrfUnsupportedEncoding = "unsupported encoding: %v"
+)
+
+// LookupEncoding finds an encoding by its name. Labels of the WHATWG encoding
+// standard, IANA names and aliases, and names of 'golang.org/x/text' charmaps
+// are recognised, e.g. 'utf8', 'cp1251', 'koi8-r', 'ibm866', 'shift_jis',
+// 'euc-jp', 'gbk', 'big5', 'euc-kr' or 'IBM Code Page 437'. Names are case
+// insensitive.
+func LookupEncoding(name string) (enc encoding.Encoding, err error) {
+	name = strings.TrimSpace(name)
+
+	enc, err = htmlindex.Get(name)
+	if err == nil {
+		return enc, nil
+	}
+
+	// Some IANA names are known, but are not supported.
+	enc, err = ianaindex.IANA.Encoding(name)
+	if (err == nil) && (enc != nil) {
+		return enc, nil
+	}
+
+	for _, e := range charmap.All {
+		cm, ok := e.(*charmap.Charmap)
+		if ok && strings.EqualFold(cm.String(), name) {
+			return cm, nil
+		}
+	}
+
+	return nil, fmt.Errorf(ErrfUnsupportedEncoding, name)
+}
+
+// DecodePage decodes a page into UTF-8. A byte order mark at the beginning of
+// the page takes precedence over the encoding and is removed. When the
+// encoding is nil, it is detected as browsers do: by the charset of the
+// 'Content-Type' header, by '<meta charset>' and '<meta http-equiv>' tags at
+// the beginning of the page and, at last, by validity of UTF-8.
+func DecodePage(pageContents []byte, contentType string, enc encoding.Encoding) (utfOutput []byte, err error) {
+	if enc == nil {
+		enc, _, _ = charset.DetermineEncoding(pageContents, contentType)
+	}
+
+	utfOutput, _, err = transform.Bytes(unicode.BOMOverride(enc.NewDecoder()), pageContents)
+	if err != nil {
+		return nil, err
+	}
+
+	return utfOutput, nil
+}
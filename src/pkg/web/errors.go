@@ -0,0 +1,54 @@
+package web
+
+import (
+	"fmt"
+)
+
+const (
+	ErrfStatus            = "unexpected status of %v: %v"
+	ErrfNotLoggedIn       = "not logged in: %v"
+	ErrfForumDoesNotExist = "forum does not exist: %v"
+	ErrfCaptcha           = "captcha is requested: %v"
+)
+
+// StatusError is returned when a server responds with a status code other
+// than '200 OK'.
+type StatusError struct {
+	Url        string
+	StatusCode int
+	Status     string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf(ErrfStatus, e.Url, e.Status)
+}
+
+// NotLoggedInError is returned when a page is shown to a guest, i.e. the
+// session cookie has expired. Crawling should be stopped.
+type NotLoggedInError struct {
+	Url string
+}
+
+func (e *NotLoggedInError) Error() string {
+	return fmt.Sprintf(ErrfNotLoggedIn, e.Url)
+}
+
+// ForumDoesNotExistError is returned when a forum has been deleted or is not
+// accessible. The forum may be skipped.
+type ForumDoesNotExistError struct {
+	Url string
+}
+
+func (e *ForumDoesNotExistError) Error() string {
+	return fmt.Sprintf(ErrfForumDoesNotExist, e.Url)
+}
+
+// CaptchaError is returned when a server asks to solve a captcha. Crawling
+// may be continued after a pause.
+type CaptchaError struct {
+	Url string
+}
+
+func (e *CaptchaError) Error() string {
+	return fmt.Sprintf(ErrfCaptcha, e.Url)
+}
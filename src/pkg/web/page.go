@@ -0,0 +1,61 @@
+package web
+
+import (
+	"bytes"
+)
+
+// Markers of special pages. Pages are searched for the markers after they are
+// decoded into UTF-8. Markers are parts of HTML markup rather than plain text
+// where possible, so that names of topics do not trigger them.
+var (
+	// Login forms shown to guests.
+	notLoggedInMarkers = [][]byte{
+		[]byte(`name="login_username"`),
+		[]byte(`name="username" id="username"`),
+	}
+
+	// Messages about forums which do not exist or may not be viewed.
+	forumDoesNotExistMarkers = [][]byte{
+		[]byte(`Такого форума не существует`),
+		[]byte(`The forum you selected does not exist.`),
+		[]byte(`The requested forum does not exist.`),
+	}
+
+	// Captcha forms and anti-bot challenges.
+	captchaMarkers = [][]byte{
+		[]byte(`name="cap_sid"`),
+		[]byte(`class="g-recaptcha"`),
+		[]byte(`class="h-captcha"`),
+		[]byte(`class="cf-turnstile"`),
+		[]byte(`id="challenge-form"`),
+	}
+)
+
+// CheckPage searches a page for markers of special pages and returns a typed
+// error when the page is not a normal page. Login forms are searched for only
+// when the crawler is expected to be logged in.
+func CheckPage(url string, pageContents []byte, expectLoggedIn bool) (err error) {
+	if containsAny(pageContents, captchaMarkers) {
+		return &CaptchaError{Url: url}
+	}
+
+	if expectLoggedIn && containsAny(pageContents, notLoggedInMarkers) {
+		return &NotLoggedInError{Url: url}
+	}
+
+	if containsAny(pageContents, forumDoesNotExistMarkers) {
+		return &ForumDoesNotExistError{Url: url}
+	}
+
+	return nil
+}
+
+func containsAny(data []byte, markers [][]byte) bool {
+	for _, marker := range markers {
+		if bytes.Contains(data, marker) {
+			return true
+		}
+	}
+
+	return false
+}